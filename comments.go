@@ -0,0 +1,80 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WithSkipBlankLines modifies a Decoder to skip lines that are empty or
+// contain only whitespace, instead of decoding them as lines without tokens.
+func (d *Decoder) WithSkipBlankLines() *Decoder {
+	d.skipBlank = true
+	return d
+}
+
+// WithCommentPrefixes modifies a Decoder to skip lines that start with any of
+// the given prefixes, such as "#" or "//", ignoring leading whitespace.  It
+// replaces any previous comment prefixes.
+func (d *Decoder) WithCommentPrefixes(prefixes ...string) *Decoder {
+	d.comments = nil
+	for _, p := range prefixes {
+		if p != "" {
+			d.comments = append(d.comments, p)
+		}
+	}
+	return d
+}
+
+// WithInlineComments modifies a Decoder to also remove comments that follow
+// other text on a line, for comment prefixes given to `WithCommentPrefixes`.
+// A comment prefix that follows whitespace starts an inline comment, so
+// "a # note" becomes "a", but "a#b" is unchanged.  The comment and the
+// whitespace before it are removed before the line is tokenized.  Quoting
+// isn't recognized, so a comment prefix within a quoted token still starts a
+// comment.
+func (d *Decoder) WithInlineComments() *Decoder {
+	d.inlineComments = true
+	return d
+}
+
+// ignoreLine reports whether a line is blank or a comment line that should
+// be skipped, regardless of the tokenizer.
+func (d *Decoder) ignoreLine(s string) bool {
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	if trimmed == "" {
+		return d.skipBlank
+	}
+	for _, p := range d.comments {
+		if strings.HasPrefix(trimmed, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripComment removes an inline comment and the whitespace before it from
+// a line, if inline comments are enabled.
+func (d *Decoder) stripComment(s string) string {
+	if !d.inlineComments || len(d.comments) == 0 {
+		return s
+	}
+	for i, r := range s {
+		if !unicode.IsSpace(r) {
+			continue
+		}
+		rest := s[i+utf8.RuneLen(r):]
+		for _, p := range d.comments {
+			if strings.HasPrefix(rest, p) {
+				return strings.TrimRightFunc(s[:i], unicode.IsSpace)
+			}
+		}
+	}
+	return s
+}
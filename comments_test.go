@@ -0,0 +1,116 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestSkipLines(t *testing.T) {
+	type setting struct {
+		Key   string
+		Value int
+	}
+
+	input := "# settings\n\nwidth 80\n   \n  // height\nheight 24 # rows\ndepth 3#4\n"
+
+	cases := []struct {
+		label       string
+		setup       func(d *strum.Decoder)
+		errContains string
+	}{
+		{
+			label:       "no skipping",
+			setup:       func(d *strum.Decoder) {},
+			errContains: `line 1: token 1 "settings"`,
+		},
+		{
+			label:       "blank lines only",
+			setup:       func(d *strum.Decoder) { d.WithSkipBlankLines() },
+			errContains: `line 1: token 1 "settings"`,
+		},
+		{
+			label:       "comments without inline comments",
+			setup:       func(d *strum.Decoder) { d.WithSkipBlankLines().WithCommentPrefixes("#", "//") },
+			errContains: "line 6: too many tokens",
+		},
+		{
+			label:       "comments without blank lines",
+			setup:       func(d *strum.Decoder) { d.WithCommentPrefixes("#", "//").WithInlineComments() },
+			errContains: "line 4: too many tokens",
+		},
+		{
+			label: "all",
+			setup: func(d *strum.Decoder) {
+				d.WithSkipBlankLines().WithCommentPrefixes("#", "//").WithInlineComments()
+			},
+			errContains: `line 7: token 1 "3#4"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(input)).WithSplitOn(" ")
+			c.setup(d)
+			var got []setting
+			err := d.DecodeAll(&got)
+			errContains(t, err, c.errContains, "decoding")
+		})
+	}
+
+	t.Run("decoded values", func(t *testing.T) {
+		input := "# settings\n\nwidth 80\n   \n  // height\nheight 24 # rows\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).
+			WithSkipBlankLines().
+			WithCommentPrefixes("#", "//").
+			WithInlineComments()
+		var got []setting
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []setting{{"width", 80}, {"height", 24}}, got, "settings")
+	})
+
+	t.Run("line numbers", func(t *testing.T) {
+		input := "# ints\n\n1\n\nx\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithSkipBlankLines().WithCommentPrefixes("#")
+		var got []int
+		err := d.DecodeAll(&got)
+		var de *strum.DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("expected DecodeError, got %v", err)
+		}
+		isWantGot(t, 5, de.Line, "error line")
+		isWantGot(t, []int{1}, got, "decoded before error")
+	})
+
+	t.Run("header after comments", func(t *testing.T) {
+		input := "# generated\nkey value\nwidth 80\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithCommentPrefixes("#").WithHeader()
+		var got []setting
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []setting{{"width", 80}}, got, "settings")
+	})
+
+	t.Run("kept with new tokenizer", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("# a,b\n1,2\n")).WithCommentPrefixes("#").WithSplitOn(",")
+		var got []int
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []int{1, 2}, got, "ints")
+	})
+}
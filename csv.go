@@ -0,0 +1,157 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVOptions configures the tokenizer used by `WithCSV`.  The zero value
+// tokenizes RFC 4180 CSV records.
+type CSVOptions struct {
+	// Delimiter separates fields.  If zero, it defaults to ','.
+	Delimiter rune
+	// Quote encloses fields that contain delimiters, quotes, or newlines.
+	// Quotes within a quoted field are escaped by doubling them.  If zero,
+	// it defaults to '"'.
+	Quote rune
+	// LazyQuotes allows a quote to appear in an unquoted field and a
+	// non-doubled quote to appear in a quoted field.
+	LazyQuotes bool
+	// Comment, if not zero, marks a line to be skipped when it is the
+	// first character of a record.
+	Comment rune
+}
+
+var errUnterminatedQuote = errors.New("unterminated quoted field")
+
+func (o CSVOptions) withDefaults() CSVOptions {
+	if o.Delimiter == 0 {
+		o.Delimiter = ','
+	}
+	if o.Quote == 0 {
+		o.Quote = '"'
+	}
+	return o
+}
+
+func (o CSVOptions) validate() error {
+	switch {
+	case !validCSVRune(o.Delimiter):
+		return fmt.Errorf("invalid delimiter %q", o.Delimiter)
+	case !validCSVRune(o.Quote):
+		return fmt.Errorf("invalid quote %q", o.Quote)
+	case o.Comment != 0 && !validCSVRune(o.Comment):
+		return fmt.Errorf("invalid comment character %q", o.Comment)
+	case o.Delimiter == o.Quote || o.Delimiter == o.Comment || o.Quote == o.Comment:
+		return errors.New("delimiter, quote, and comment characters must differ")
+	}
+	return nil
+}
+
+func validCSVRune(r rune) bool {
+	return r != '\r' && r != '\n' && r != utf8.RuneError && utf8.ValidRune(r)
+}
+
+// tokenize splits a CSV record into fields.  The record may contain newlines
+// within quoted fields.  If the record ends inside a quoted field, it returns
+// errUnterminatedQuote.  It also returns the offset where each field starts.
+func (o CSVOptions) tokenize(s string) ([]string, []int, error) {
+	var fields []string
+	var offsets []int
+	var field strings.Builder
+	i := 0
+	for {
+		field.Reset()
+		offsets = append(offsets, i)
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if i < len(s) && r == o.Quote {
+			i += size
+		quoted:
+			for {
+				if i >= len(s) {
+					return nil, nil, errUnterminatedQuote
+				}
+				r, size = utf8.DecodeRuneInString(s[i:])
+				i += size
+				if r != o.Quote {
+					field.WriteRune(r)
+					continue
+				}
+				// A quote is either escaped, closing, or stray.
+				r, size = utf8.DecodeRuneInString(s[i:])
+				switch {
+				case i < len(s) && r == o.Quote:
+					field.WriteRune(o.Quote)
+					i += size
+				case i >= len(s) || r == o.Delimiter:
+					break quoted
+				case o.LazyQuotes:
+					field.WriteRune(o.Quote)
+				default:
+					return nil, nil, fmt.Errorf("extraneous %q in field %d", o.Quote, len(fields)+1)
+				}
+			}
+		} else {
+			for i < len(s) {
+				r, size = utf8.DecodeRuneInString(s[i:])
+				if r == o.Delimiter {
+					break
+				}
+				if r == o.Quote && !o.LazyQuotes {
+					return nil, nil, fmt.Errorf("bare %q in non-quoted field %d", o.Quote, len(fields)+1)
+				}
+				field.WriteRune(r)
+				i += size
+			}
+		}
+
+		fields = append(fields, field.String())
+		if i >= len(s) {
+			return fields, offsets, nil
+		}
+		// Skip the delimiter
+		_, size = utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+}
+
+// WithCSV modifies a Decoder to tokenize lines as CSV records, as described
+// in RFC 4180, with the given options.  Unlike `WithSplitOn`, delimiters
+// within quoted fields are not split.  A record continues onto the following
+// lines of input while a quoted field is open, so a quoted field may contain
+// newlines.  Blank lines and comment lines are skipped.
+func (d *Decoder) WithCSV(opts CSVOptions) *Decoder {
+	opts = opts.withDefaults()
+	optsErr := opts.validate()
+	d.withSpanTokenizer(
+		func(s string) ([]string, []int, error) {
+			if optsErr != nil {
+				return nil, nil, fmt.Errorf("invalid CSV options: %w", optsErr)
+			}
+			return opts.tokenize(s)
+		},
+	)
+	d.more = func(s string) bool {
+		if optsErr != nil {
+			return false
+		}
+		_, _, err := opts.tokenize(s)
+		return err == errUnterminatedQuote
+	}
+	d.skip = func(s string) bool {
+		if s == "" {
+			return true
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		return opts.Comment != 0 && r == opts.Comment
+	}
+	return d
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestCSVTokens(t *testing.T) {
+	cases := []struct {
+		label       string
+		opts        strum.CSVOptions
+		input       string
+		want        [][]string
+		errContains string
+	}{
+		{
+			label: "simple",
+			input: "a,b,c\n1,2,3",
+			want:  [][]string{{"a", "b", "c"}, {"1", "2", "3"}},
+		},
+		{
+			label: "empty fields",
+			input: ",a,,",
+			want:  [][]string{{"", "a", "", ""}},
+		},
+		{
+			label: "quoted delimiter",
+			input: `"Doe, John",42`,
+			want:  [][]string{{"Doe, John", "42"}},
+		},
+		{
+			label: "escaped quote",
+			input: `"say ""hi""",x`,
+			want:  [][]string{{`say "hi"`, "x"}},
+		},
+		{
+			label: "multi-line field",
+			input: "\"line one\nline two\",x\nnext,y",
+			want:  [][]string{{"line one\nline two", "x"}, {"next", "y"}},
+		},
+		{
+			label: "blank lines skipped",
+			input: "a,b\n\nc,d",
+			want:  [][]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			label: "custom delimiter, quote and comment",
+			opts:  strum.CSVOptions{Delimiter: ';', Quote: '\'', Comment: '#'},
+			input: "# comment\n'a;b';c\n#another\nd;e",
+			want:  [][]string{{"a;b", "c"}, {"d", "e"}},
+		},
+		{
+			label: "lazy quotes",
+			opts:  strum.CSVOptions{LazyQuotes: true},
+			input: `a"b,"c"d"`,
+			want:  [][]string{{`a"b`, `c"d`}},
+		},
+		{
+			label:       "bare quote",
+			input:       `a"b,c`,
+			errContains: `bare '"' in non-quoted field 1`,
+		},
+		{
+			label:       "extraneous quote",
+			input:       `a,"b"c`,
+			errContains: `extraneous '"' in field 2`,
+		},
+		{
+			label:       "unterminated quote",
+			input:       "a,\"b\nc",
+			errContains: "unterminated quoted field",
+		},
+		{
+			label:       "invalid options",
+			opts:        strum.CSVOptions{Delimiter: '"'},
+			input:       "a,b",
+			errContains: "invalid CSV options",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithCSV(c.opts)
+			var got [][]string
+			for {
+				tokens, err := d.Tokens()
+				if err == io.EOF {
+					break
+				}
+				if c.errContains != "" {
+					errContains(t, err, c.errContains, "tokenizing")
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tokens)
+			}
+			if c.errContains != "" {
+				t.Fatalf("expected error containing '%s', but got no error", c.errContains)
+			}
+			isWantGot(t, c.want, got, "tokens")
+		})
+	}
+}
+
+func TestCSVDecodeAll(t *testing.T) {
+	type note struct {
+		Author string
+		Stars  int
+		Text   string
+	}
+
+	input := "author,stars,text\n" +
+		"\"Doe, John\",5,\"Great,\nwould buy again\"\n" +
+		"Jane,3,ok\n"
+
+	d := strum.NewDecoder(bytes.NewBufferString(input)).WithCSV(strum.CSVOptions{}).WithHeader()
+	var got []note
+	err := d.DecodeAll(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []note{
+		{"Doe, John", 5, "Great,\nwould buy again"},
+		{"Jane", 3, "ok"},
+	}
+	isWantGot(t, want, got, "decoded notes")
+}
@@ -0,0 +1,377 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// An Encoder writes Go values as lines of text, the reverse of a Decoder.
+type Encoder struct {
+	w      io.Writer
+	sep    string
+	layout string
+	null   string
+	quote  bool
+
+	alignNumbers bool
+}
+
+// NewEncoder returns an Encoder that writes to w.  The default Encoder
+// separates tokens with a single space, formats times with
+// `time.RFC3339Nano`, and writes nil pointers as empty tokens, which require
+// `WithQuoting` or a separator other than whitespace.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:      w,
+		sep:    " ",
+		layout: time.RFC3339Nano,
+	}
+}
+
+// WithSeparator modifies an Encoder to separate tokens with a string, such
+// as "," for input to be decoded with `WithSplitOn`.
+func (e *Encoder) WithSeparator(sep string) *Encoder {
+	e.sep = sep
+	return e
+}
+
+// WithTimeLayout modifies an Encoder to format time.Time values with a
+// layout for `time.Time.Format`.  A struct field with a `layout` tag option
+// is formatted with that layout instead, in the time zone of its `tz` option,
+// if any.
+func (e *Encoder) WithTimeLayout(layout string) *Encoder {
+	e.layout = layout
+	return e
+}
+
+// WithQuoting modifies an Encoder to quote tokens for a Decoder configured
+// with `WithQuotedFields`, so that every token reads back as written.  Tokens
+// that are empty or contain whitespace, quotes, backslashes, or invalid UTF-8
+// are enclosed in single quotes.  Quoted tokens may contain newlines, which
+// continue onto the following lines of output, except that a carriage return
+// may not precede a newline.  Quoting requires a separator of whitespace, like
+// the default.  As nil pointers are written as empty tokens unless
+// `WithNullToken` says otherwise, they are quoted too; the Decoder must treat
+// the empty token as null, with `WithNullTokens("")`, to read them back as nil.
+//
+// A string written as an entire line isn't quoted, as it isn't tokenized when
+// decoded, and neither is a string field with the `rest` tag option, which
+// decodes from the rest of the line.  It is an error if either would not read
+// back as written, such as if it has a newline or an unmatched quote, or, for
+// a `rest` field, leading whitespace.
+func (e *Encoder) WithQuoting() *Encoder {
+	e.quote = true
+	return e
+}
+
+// WithNullToken modifies an Encoder to write nil pointers as a token, such
+// as "-" for input to be decoded with `WithNullTokens`.  A struct field with
+// `null` tag options is written with the first of those instead.
+func (e *Encoder) WithNullToken(tok string) *Encoder {
+	e.null = tok
+	return e
+}
+
+// Encode writes a value as a line of output.  It encodes any type that a
+// Decoder can decode into, except for types that only implement
+// `encoding.TextUnmarshaler` without `encoding.TextMarshaler` and types with
+// custom converters.  A single value is written as a single token.  A string
+// is written as-is, as it would decode from an entire line.  Slice elements
+// and struct fields are written as tokens in the order a Decoder would map
+// them, with any tokens between mapped struct fields left empty.
+//
+// It is an error if a token contains a newline.  Unless the Encoder is
+// configured with `WithQuoting`, it is also an error if a token would not
+// decode as written: if it contains the separator or, when the separator is
+// whitespace, if it is empty or contains whitespace.  A string field with the
+// `rest` tag option may contain the separator, as it decodes from the rest of
+// the line.
+func (e *Encoder) Encode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return errors.New("Encode: cannot encode nil")
+	}
+	return e.encode(rv)
+}
+
+// EncodeAll writes each element of a slice as a line of output, as if by
+// `Encode`.  The argument may be a slice or a pointer to a slice.
+func (e *Encoder) EncodeAll(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("EncodeAll: argument must be a slice or pointer to a slice, not %T", v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		err := e.encode(rv.Index(i))
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Marshal returns the elements of a slice as newline delimited lines of text,
+// as written by an Encoder with default options.  It is the inverse of
+// `Unmarshal`.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).EncodeAll(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *Encoder) encode(rv reflect.Value) error {
+	p := planFor(rv.Type())
+	if p.err != nil {
+		return p.err
+	}
+	// Copy the value so that methods with pointer receivers may be used.
+	if !rv.CanAddr() {
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+	if e.quote && !e.whitespaceSep() {
+		return fmt.Errorf("cannot quote tokens with separator %q", e.sep)
+	}
+	line, err := e.formatPlan(p, rv)
+	if err != nil {
+		return err
+	}
+	if !e.quote && strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("cannot encode %q: contains a newline", line)
+	}
+	_, err = io.WriteString(e.w, line+"\n")
+	return err
+}
+
+// whitespaceSep reports whether the Encoder's separator is whitespace.
+func (e *Encoder) whitespaceSep() bool {
+	return e.sep != "" && strings.TrimFunc(e.sep, unicode.IsSpace) == ""
+}
+
+// join joins tokens into a line, quoting them if needed.  If rest is true,
+// the last token is from a `rest` field and isn't quoted.
+func (e *Encoder) join(tokens []string, rest bool) (string, error) {
+	if !e.quote {
+		// Check that each token would decode as written.
+		ws := e.whitespaceSep()
+		for i, tok := range tokens {
+			switch {
+			case strings.ContainsAny(tok, "\r\n"):
+				return "", fmt.Errorf("cannot encode %q: contains a newline", tok)
+			case ws && tok == "":
+				return "", fmt.Errorf("cannot encode an empty token at index %d without quoting", i)
+			case rest && i == len(tokens)-1:
+				// A rest field decodes from the rest of the line.
+			case ws && strings.IndexFunc(tok, unicode.IsSpace) >= 0:
+				return "", fmt.Errorf("cannot encode %q: contains whitespace", tok)
+			case !ws && e.sep != "" && strings.Contains(tok, e.sep):
+				return "", fmt.Errorf("cannot encode %q: contains separator %q", tok, e.sep)
+			}
+		}
+		return strings.Join(tokens, e.sep), nil
+	}
+	quoted := make([]string, len(tokens))
+	for i, tok := range tokens {
+		if rest && i == len(tokens)-1 {
+			s, err := e.formatRest(tok)
+			if err != nil {
+				return "", err
+			}
+			quoted[i] = s
+			continue
+		}
+		if strings.Contains(tok, "\r\n") {
+			return "", fmt.Errorf("cannot encode %q: contains a carriage return before a newline", tok)
+		}
+		quoted[i] = shellQuote(tok)
+	}
+	return strings.Join(quoted, e.sep), nil
+}
+
+// formatLine checks a string to be written as an entire line.
+func (e *Encoder) formatLine(s string) (string, error) {
+	if e.quote && (strings.ContainsAny(s, "\r\n") || shellIncomplete(s)) {
+		return "", fmt.Errorf("cannot encode %q: would not decode as a single line", s)
+	}
+	return s, nil
+}
+
+// formatRest checks a quoting Encoder's token for a `rest` field, which is
+// written unquoted as it is decoded from the rest of the line.
+func (e *Encoder) formatRest(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") || shellIncomplete(s) || strings.IndexFunc(s, unicode.IsSpace) == 0 {
+		return "", fmt.Errorf("cannot encode %q: would not decode as the rest of the line", s)
+	}
+	return s, nil
+}
+
+// formatPlan formats a value as a line, following the decoding plan for its
+// type.
+func (e *Encoder) formatPlan(p *decodePlan, rv reflect.Value) (string, error) {
+	switch p.kind {
+	case planToken:
+		tok, err := e.formatToken(rv, tokenFormat{})
+		if err != nil {
+			return "", err
+		}
+		return e.join([]string{tok}, false)
+	case planLine:
+		return e.formatLine(rv.String())
+	case planStruct:
+		return e.formatStruct(p, rv)
+	case planSlice:
+		tokens, err := e.formatSlice(rv, tokenFormat{})
+		if err != nil {
+			return "", err
+		}
+		return e.join(tokens, false)
+	case planPtr:
+		if rv.IsNil() {
+			return "", fmt.Errorf("cannot encode nil %s", rv.Type())
+		}
+		return e.formatPlan(p.elem, rv.Elem())
+	default:
+		return "", p.err
+	}
+}
+
+func (e *Encoder) formatStruct(p *decodePlan, rv reflect.Value) (string, error) {
+	tokens := make([]string, p.numTokens)
+	for _, f := range p.fields {
+		tf := fieldFormat(f)
+		fieldValue := rv.Field(f.index)
+		if f.variadic {
+			elems, err := e.formatSlice(fieldValue, tf)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %w", f.name, err)
+			}
+			tokens = append(tokens[:f.token], elems...)
+			continue
+		}
+
+		tok, err := e.formatToken(fieldValue, tf)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %w", f.name, err)
+		}
+		tokens[f.token] = tok
+	}
+	return e.join(tokens, p.rest)
+}
+
+func (e *Encoder) formatSlice(rv reflect.Value, tf tokenFormat) ([]string, error) {
+	tokens := make([]string, rv.Len())
+	for i := range tokens {
+		tok, err := e.formatToken(rv.Index(i), tf)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		tokens[i] = tok
+	}
+	return tokens, nil
+}
+
+// tokenFormat holds options for formatting a token from a struct tag.
+type tokenFormat struct {
+	layout string
+	loc    *time.Location
+	base   int
+	null   *string
+}
+
+// fieldFormat returns the options for formatting a struct field.
+func fieldFormat(f fieldInfo) tokenFormat {
+	tf := tokenFormat{layout: f.layout, loc: f.loc, base: f.base}
+	if len(f.nulls) > 0 {
+		tf.null = &f.nulls[0]
+	}
+	return tf
+}
+
+// formatToken formats a value as a single token.  It follows the logic tree
+// of `newConverter` in reverse.
+func (e *Encoder) formatToken(rv reflect.Value, tf tokenFormat) (string, error) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			if tf.null != nil {
+				return *tf.null, nil
+			}
+			return e.null, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Type() {
+	case durationType:
+		return time.Duration(rv.Int()).String(), nil
+	case timeType:
+		t := rv.Interface().(time.Time)
+		layout := e.layout
+		if tf.layout != "" {
+			layout = tf.layout
+		}
+		if tf.loc != nil {
+			t = t.In(tf.loc)
+		}
+		return t.Format(layout), nil
+	}
+
+	if tm, ok := textMarshaler(rv); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+
+	base := tf.base
+	if base == 0 {
+		base = 10
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), base), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), base), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("cannot encode type %s", rv.Type())
+	}
+}
+
+// textMarshaler returns a value as an encoding.TextMarshaler if it implements
+// it directly or, if it is addressable, with a pointer receiver.
+func textMarshaler(rv reflect.Value) (encoding.TextMarshaler, bool) {
+	if rv.Type().Implements(textMarshalerType) {
+		return rv.Interface().(encoding.TextMarshaler), true
+	}
+	if rv.CanAddr() && reflect.PointerTo(rv.Type()).Implements(textMarshalerType) {
+		return rv.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
@@ -0,0 +1,464 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+	"unicode"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xdg-go/strum"
+)
+
+func TestEncode(t *testing.T) {
+	type server struct {
+		Name    string
+		Port    uint16
+		Enabled bool
+		Load    float64
+		Timeout time.Duration
+		Started time.Time
+		IP      net.IP
+		Tags    []string
+	}
+
+	type tagged struct {
+		Mask  uint8      `strum:",base=2"`
+		Day   time.Time  `strum:",layout=2006-01-02,tz=UTC"`
+		Score *int       `strum:",null=NA"`
+		When  *time.Time `strum:"5"`
+	}
+
+	type commit struct {
+		Hash    string
+		Subject string `strum:",rest"`
+	}
+
+	started := time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC)
+	day := time.Date(2021, 3, 4, 23, 0, 0, 0, time.FixedZone("", -2*60*60))
+
+	cases := []struct {
+		label       string
+		value       interface{}
+		setup       func(e *strum.Encoder)
+		want        string
+		errContains string
+	}{
+		{
+			label: "struct",
+			value: server{"web", 80, true, 0.25, 90 * time.Second, started, net.ParseIP("10.0.0.1"), []string{"a", "b"}},
+			want:  "web 80 true 0.25 1m30s 2021-03-04T05:06:07.0000008Z 10.0.0.1 a b\n",
+		},
+		{
+			label: "pointer to struct",
+			value: &server{Name: "db", Started: started, IP: net.ParseIP("10.0.0.2")},
+			want:  "db 0 false 0 0s 2021-03-04T05:06:07.0000008Z 10.0.0.2\n",
+		},
+		{
+			label:       "empty token",
+			value:       server{Name: "db", Started: started},
+			errContains: "cannot encode an empty token at index 6 without quoting",
+		},
+		{
+			label: "separator and time layout",
+			value: server{Name: "web", Port: 80, Started: started},
+			setup: func(e *strum.Encoder) { e.WithSeparator(",").WithTimeLayout("2006-01-02") },
+			want:  "web,80,false,0,0s,2021-03-04,\n",
+		},
+		{
+			label: "tag options",
+			value: tagged{Mask: 5, Day: day},
+			setup: func(e *strum.Encoder) { e.WithNullToken("-").WithSeparator(",") },
+			want:  "101,2021-03-05,NA,,,-\n",
+		},
+		{
+			label:       "gap between tag indexes",
+			value:       tagged{Mask: 5, Day: day},
+			errContains: "cannot encode an empty token at index 3 without quoting",
+		},
+		{
+			label: "single token",
+			value: big.NewRat(3, 4),
+			want:  "3/4\n",
+		},
+		{
+			label: "line",
+			value: "hello  world",
+			want:  "hello  world\n",
+		},
+		{
+			label: "slice",
+			value: []int{1, -2, 3},
+			setup: func(e *strum.Encoder) { e.WithSeparator("\t") },
+			want:  "1\t-2\t3\n",
+		},
+		{
+			label: "rest field",
+			value: commit{"abc", "Fix the bug, again"},
+			want:  "abc Fix the bug, again\n",
+		},
+		{
+			label: "rest field with separator",
+			value: commit{"abc", "Fix the bug, again"},
+			setup: func(e *strum.Encoder) { e.WithSeparator(",") },
+			want:  "abc,Fix the bug, again\n",
+		},
+		{
+			label:       "whitespace in token",
+			value:       []string{"John Smith", "3"},
+			errContains: `cannot encode "John Smith": contains whitespace`,
+		},
+		{
+			label:       "separator in token",
+			value:       []string{"a,b", "3"},
+			setup:       func(e *strum.Encoder) { e.WithSeparator(",") },
+			errContains: `cannot encode "a,b": contains separator ","`,
+		},
+		{
+			label:       "newline",
+			value:       []string{"a", "b\nc"},
+			errContains: `cannot encode "b\nc": contains a newline`,
+		},
+		{
+			label:       "nil",
+			value:       nil,
+			errContains: "cannot encode nil",
+		},
+		{
+			label:       "nil pointer",
+			value:       (*server)(nil),
+			errContains: "cannot encode nil *strum_test.server",
+		},
+		{
+			label:       "unsupported type",
+			value:       map[string]int{},
+			errContains: "cannot decode into type map[string]int",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := strum.NewEncoder(&buf)
+			if c.setup != nil {
+				c.setup(e)
+			}
+			err := e.Encode(c.value)
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "encoding")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, buf.String(), "encoded line")
+		})
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	type person struct {
+		Name   string
+		Age    int
+		Joined time.Time
+	}
+
+	people := []person{
+		{"John", 42, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"Jane", 23, time.Date(2022, 2, 22, 12, 30, 0, 0, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	err := strum.NewEncoder(&buf).EncodeAll(&people)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, "John 42 2020-03-01T00:00:00Z\nJane 23 2022-02-22T12:30:00Z\n", buf.String(), "encoded lines")
+
+	var got []person
+	err = strum.NewDecoder(&buf).DecodeAll(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, people, got, "round trip")
+
+	err = strum.NewEncoder(&buf).EncodeAll(people[0])
+	errContains(t, err, "EncodeAll: argument must be a slice or pointer to a slice", "encoding non-slice")
+}
+
+func TestMarshal(t *testing.T) {
+	xs := []string{"hello world", "goodbye world"}
+	data, err := strum.Marshal(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, "hello world\ngoodbye world\n", string(data), "marshaled strings")
+
+	var got []string
+	err = strum.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, xs, got, "round trip")
+}
+
+func TestEncodeQuoting(t *testing.T) {
+	type record struct {
+		Name string
+		Note string
+		Tags []string
+	}
+
+	type commit struct {
+		Hash    string
+		Subject string `strum:",rest"`
+	}
+
+	cases := []struct {
+		label       string
+		value       interface{}
+		setup       func(e *strum.Encoder)
+		want        string
+		errContains string
+	}{
+		{
+			label: "plain tokens",
+			value: record{"a", "b", []string{"c"}},
+			want:  "a b c\n",
+		},
+		{
+			label: "quoted tokens",
+			value: record{"", "it's a \"test\"", []string{`C:\dir`, "tab\there"}},
+			want:  `'' 'it'\''s a "test"' 'C:\dir' 'tab` + "\t" + `here'` + "\n",
+		},
+		{
+			label: "newline in token",
+			value: record{"a", "b\nc", nil},
+			want:  "a 'b\nc'\n",
+		},
+		{
+			label:       "carriage return before newline",
+			value:       record{"a", "b\r\nc", nil},
+			errContains: "contains a carriage return before a newline",
+		},
+		{
+			label: "line",
+			value: "it is a line",
+			want:  "it is a line\n",
+		},
+		{
+			label:       "incomplete line",
+			value:       "it's a line",
+			errContains: `cannot encode "it's a line": would not decode as a single line`,
+		},
+		{
+			label: "rest field",
+			value: commit{"a b", `Say "hi" to 'them'`},
+			want:  `'a b' Say "hi" to 'them'` + "\n",
+		},
+		{
+			label:       "incomplete rest field",
+			value:       commit{"abc", "Don't panic"},
+			errContains: `cannot encode "Don't panic": would not decode as the rest of the line`,
+		},
+		{
+			label:       "rest field with leading space",
+			value:       commit{"abc", " indented"},
+			errContains: "would not decode as the rest of the line",
+		},
+		{
+			label:       "non-whitespace separator",
+			value:       record{},
+			setup:       func(e *strum.Encoder) { e.WithSeparator(",") },
+			errContains: `cannot quote tokens with separator ","`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := strum.NewEncoder(&buf).WithQuoting()
+			if c.setup != nil {
+				c.setup(e)
+			}
+			err := e.Encode(c.value)
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "encoding")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, buf.String(), "encoded line")
+		})
+	}
+}
+
+// quickRecord has a field of each kind of type a Decoder supports.
+type quickRecord struct {
+	S   string
+	B   bool
+	I   int
+	I8  int8
+	I16 int16
+	I32 int32
+	I64 int64
+	U   uint
+	U8  uint8
+	U16 uint16
+	U32 uint32
+	U64 uint64
+	F32 float32
+	F64 float64
+	D   time.Duration
+	T   time.Time
+	IP  net.IP
+	P   *int
+	R   string `strum:",rest"`
+}
+
+// Generate implements quick.Generator, since time.Time can't be generated
+// automatically.
+func (quickRecord) Generate(r *rand.Rand, size int) reflect.Value {
+	v := reflect.New(reflect.TypeOf(quickRecord{})).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Interface().(type) {
+		case time.Time:
+			// Keep times within years that date parsing supports.
+			nanos := r.Int63n(200 * 365 * 24 * int64(time.Hour))
+			zone := time.FixedZone("", (r.Intn(27*4)-12*4)*15*60)
+			f.Set(reflect.ValueOf(time.Unix(0, nanos).In(zone)))
+		case net.IP:
+			ip := make(net.IP, net.IPv6len)
+			r.Read(ip)
+			f.Set(reflect.ValueOf(ip))
+		default:
+			x, _ := quick.Value(f.Type(), r)
+			f.Set(x)
+		}
+	}
+	return v
+}
+
+// noCRLF removes sequences that the quoting encoder rejects.
+func noCRLF(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// restLine removes characters that the quoting encoder rejects for a rest
+// field, which is written unquoted: line breaks, quotes, backslashes, and
+// leading whitespace.
+func restLine(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("\r\n'\"\\", r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimLeftFunc(s, unicode.IsSpace)
+}
+
+func TestQuotingRoundTrip(t *testing.T) {
+	roundTrip := func(in, out interface{}) error {
+		var buf bytes.Buffer
+		err := strum.NewEncoder(&buf).WithQuoting().Encode(in)
+		if err != nil {
+			return err
+		}
+		return strum.NewDecoder(&buf).WithQuotedFields().WithNullTokens("").Decode(out)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		f := func(in quickRecord) bool {
+			in.S = noCRLF(in.S)
+			in.R = restLine(in.R)
+			var out quickRecord
+			err := roundTrip(in, &out)
+			if err != nil {
+				t.Log(err)
+				return false
+			}
+			return cmp.Equal(in, out)
+		}
+		err := quick.Check(f, nil)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("slice field", func(t *testing.T) {
+		type record struct {
+			S  string
+			Xs []string
+		}
+		f := func(s string, xs []string) bool {
+			in := record{noCRLF(s), xs}
+			for i := range in.Xs {
+				in.Xs[i] = noCRLF(in.Xs[i])
+			}
+			var out record
+			err := roundTrip(in, &out)
+			if err != nil {
+				t.Log(err)
+				return false
+			}
+			// An empty slice decodes as nil.
+			if len(in.Xs) == 0 {
+				in.Xs = nil
+			}
+			return cmp.Equal(in, out)
+		}
+		err := quick.Check(f, nil)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("slice of strings", func(t *testing.T) {
+		f := func(in []string) bool {
+			for i := range in {
+				in[i] = noCRLF(in[i])
+			}
+			var out []string
+			err := roundTrip(in, &out)
+			if err != nil {
+				t.Log(err)
+				return false
+			}
+			return len(in) == len(out) && (len(in) == 0 || cmp.Equal(in, out))
+		}
+		err := quick.Check(f, nil)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("single values", func(t *testing.T) {
+		fs := []interface{}{
+			func(in int64) bool { var out int64; return roundTrip(in, &out) == nil && in == out },
+			func(in uint64) bool { var out uint64; return roundTrip(in, &out) == nil && in == out },
+			func(in float64) bool { var out float64; return roundTrip(in, &out) == nil && in == out },
+			func(in bool) bool { var out bool; return roundTrip(in, &out) == nil && in == out },
+			func(in time.Duration) bool { var out time.Duration; return roundTrip(in, &out) == nil && in == out },
+		}
+		for _, f := range fs {
+			err := quick.Check(f, nil)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
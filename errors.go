@@ -0,0 +1,110 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A DecodeError describes a failure to decode a line of input.  Errors
+// returned from decoding a line are of this type, so it may be retrieved with
+// `errors.As`.
+type DecodeError struct {
+	// Line is the line number of the input, starting from 1.  For a record
+	// spanning several lines, it is the line where the record starts.
+	Line int
+	// Text is the text of the line or record.
+	Text string
+	// Index is the index of the token that failed to decode, or -1 if the
+	// error isn't specific to a token.
+	Index int
+	// Field is the name of the struct field or type being decoded, if known.
+	Field string
+	// Token is the text that failed to decode, if known.
+	Token string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Index >= 0 {
+		fmt.Fprintf(&b, "token %d %q: ", e.Index, e.Token)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, "error decoding to %s: ", e.Field)
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors is a list of errors from lines that failed to decode, as
+// returned by `DecodeAllTolerant`.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no decoding errors"
+	case 1:
+		return e[0].Error()
+	default:
+		return fmt.Sprintf("%d lines failed to decode; first error: %v", len(e), e[0])
+	}
+}
+
+// Unwrap returns the individual errors, for use with `errors.Is` and
+// `errors.As` in Go 1.20 or later.
+func (e DecodeErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+func decodingError(name string, s string, err error) error {
+	return &DecodeError{Index: -1, Field: name, Token: s, Err: err}
+}
+
+// withTokenIndex records the token index on a DecodeError that doesn't
+// already have one.
+func withTokenIndex(err error, i int) error {
+	var de *DecodeError
+	if errors.As(err, &de) && de.Index < 0 {
+		de.Index = i
+	}
+	return err
+}
+
+// lineError converts an error into a DecodeError with the line number and
+// text of the current record, if a record was read.
+func (d *Decoder) lineError(err error) error {
+	if err == nil || err == io.EOF || d.recLine == 0 {
+		return err
+	}
+	var de *DecodeError
+	if errors.As(err, &de) {
+		if de.Line == 0 {
+			de.Line = d.recLine
+			de.Text = d.rec
+		}
+		return err
+	}
+	return &DecodeError{Line: d.recLine, Text: d.rec, Index: -1, Err: err}
+}
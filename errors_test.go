@@ -0,0 +1,216 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestDecodeError(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	cases := []struct {
+		label   string
+		input   string
+		setup   func(d *strum.Decoder) *strum.Decoder
+		decode  func(d *strum.Decoder) error
+		want    strum.DecodeError
+		wantMsg string
+		cause   error
+	}{
+		{
+			label: "struct field",
+			input: "John 42\nJane old\n",
+			decode: func(d *strum.Decoder) error {
+				var xs []person
+				return d.DecodeAll(&xs)
+			},
+			want:    strum.DecodeError{Line: 2, Text: "Jane old", Index: 1, Field: "person.Age", Token: "old"},
+			wantMsg: `line 2: token 1 "old": error decoding to person.Age: strconv.ParseInt: parsing "old": invalid syntax`,
+			cause:   strconv.ErrSyntax,
+		},
+		{
+			label: "slice element",
+			input: "1 2 x",
+			decode: func(d *strum.Decoder) error {
+				var xs []int
+				return d.Decode(&xs)
+			},
+			want:  strum.DecodeError{Line: 1, Text: "1 2 x", Index: 2, Field: "element 2", Token: "x"},
+			cause: strconv.ErrSyntax,
+		},
+		{
+			label: "single token",
+			input: "x",
+			decode: func(d *strum.Decoder) error {
+				var x int
+				return d.Decode(&x)
+			},
+			want:  strum.DecodeError{Line: 1, Text: "x", Index: 0, Field: "int", Token: "x"},
+			cause: strconv.ErrSyntax,
+		},
+		{
+			label: "too many tokens",
+			input: "John 42 extra",
+			decode: func(d *strum.Decoder) error {
+				var p person
+				return d.Decode(&p)
+			},
+			want:    strum.DecodeError{Line: 1, Text: "John 42 extra", Index: -1},
+			wantMsg: "line 1: too many tokens for struct strum_test.person",
+		},
+		{
+			label: "multi-line record starts on first line",
+			input: "a,1\n\"b\nc\",x\n",
+			setup: func(d *strum.Decoder) *strum.Decoder { return d.WithCSV(strum.CSVOptions{}) },
+			decode: func(d *strum.Decoder) error {
+				var xs []person
+				return d.DecodeAll(&xs)
+			},
+			want:  strum.DecodeError{Line: 2, Text: "\"b\nc\",x", Index: 1, Field: "person.Age", Token: "x"},
+			cause: strconv.ErrSyntax,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input))
+			if c.setup != nil {
+				d = c.setup(d)
+			}
+			err := c.decode(d)
+			var de *strum.DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("expected *strum.DecodeError, got %T: %v", err, err)
+			}
+			got := *de
+			got.Err = nil
+			isWantGot(t, c.want, got, "decode error")
+			if c.wantMsg != "" {
+				isWantGot(t, c.wantMsg, err.Error(), "error message")
+			}
+			if c.cause != nil && !errors.Is(err, c.cause) {
+				t.Errorf("expected error to wrap %v", c.cause)
+			}
+		})
+	}
+}
+
+func TestDecodeErrorNotForArguments(t *testing.T) {
+	d := strum.NewDecoder(bytes.NewBufferString("1"))
+	err := d.Decode(nil)
+	var de *strum.DecodeError
+	if errors.As(err, &de) {
+		t.Errorf("argument error should not be a DecodeError: %v", err)
+	}
+}
+
+func TestDecodeAllTolerant(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	input := "John 42\nJane old\nJack 36\nJill\nJoe 1 2\nJim 7\n"
+
+	t.Run("unlimited", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString(input))
+		var got []person
+		err := d.DecodeAllTolerant(&got, 0)
+
+		want := []person{{"John", 42}, {"Jack", 36}, {"Jill", 0}, {"Jim", 7}}
+		isWantGot(t, want, got, "decoded records")
+
+		var errs strum.DecodeErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected strum.DecodeErrors, got %T: %v", err, err)
+		}
+		lines := make([]int, len(errs))
+		for i, e := range errs {
+			lines[i] = e.Line
+		}
+		isWantGot(t, []int{2, 5}, lines, "failed lines")
+		errContains(t, err, "2 lines failed to decode; first error: line 2:", "aggregate message")
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString(input))
+		var got []person
+		err := d.DecodeAllTolerant(&got, 1)
+
+		want := []person{{"John", 42}, {"Jack", 36}, {"Jill", 0}}
+		isWantGot(t, want, got, "decoded records")
+
+		var errs strum.DecodeErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected strum.DecodeErrors, got %T: %v", err, err)
+		}
+		isWantGot(t, 2, len(errs), "number of errors")
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("John 42\n"))
+		var got []person
+		err := d.DecodeAllTolerant(&got, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		isWantGot(t, []person{{"John", 42}}, got, "decoded records")
+	})
+
+	t.Run("non-line error", func(t *testing.T) {
+		type badTag struct {
+			A int `strum:"-1"`
+		}
+		d := strum.NewDecoder(bytes.NewBufferString("1\n2\n"))
+		var got []badTag
+		err := d.DecodeAllTolerant(&got, 0)
+		errContains(t, err, "invalid token index", "bad struct tag")
+		var errs strum.DecodeErrors
+		if errors.As(err, &errs) {
+			t.Errorf("expected a plain error, got %T", err)
+		}
+	})
+
+	t.Run("unmapped names", func(t *testing.T) {
+		setups := map[string]func(d *strum.Decoder) *strum.Decoder{
+			"header column": func(d *strum.Decoder) *strum.Decoder { return d.WithHeader() },
+			"regexp group": func(d *strum.Decoder) *strum.Decoder {
+				return d.WithTokenRegexp(regexp.MustCompile(`^(?P<name>\S+) (?P<age>\S+) (?P<height>\S+)$`))
+			},
+		}
+		for label, setup := range setups {
+			t.Run(label, func(t *testing.T) {
+				d := setup(strum.NewDecoder(bytes.NewBufferString("name age height\nJohn 42 180\nJane 23 170\n")))
+				var got []person
+				err := d.DecodeAllTolerant(&got, 0)
+				errContains(t, err, `no field in struct strum_test.person matches name "height"`, "decoding")
+				var de *strum.DecodeError
+				if errors.As(err, &de) {
+					t.Errorf("expected a plain error, got %T", err)
+				}
+				isWantGot(t, 0, len(got), "number of decoded records")
+			})
+		}
+	})
+
+	t.Run("not a slice", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1\n"))
+		var x int
+		err := d.DecodeAllTolerant(&x, 0)
+		errContains(t, err, "DecodeAllTolerant: argument must be a pointer to slice", "non-slice")
+	})
+}
@@ -83,6 +83,91 @@ func ExampleDecoder_DecodeAll_struct() {
 	// {Jane 23 false 2022-02-22 00:00:00 +0000 UTC}
 }
 
+func ExampleDecoder_Next() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	lines := []string{
+		"John 42",
+		"Jane 23",
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	d := strum.NewDecoder(r)
+
+	for d.Next() {
+		var p person
+		err := d.Scan(&p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(p)
+	}
+	if err := d.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// {John 42}
+	// {Jane 23}
+}
+
+func ExampleDecoder_DecodeEach() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	lines := []string{
+		"John 42",
+		"Jane 23",
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	d := strum.NewDecoder(r)
+
+	var p person
+	err := d.DecodeEach(&p, func() error {
+		fmt.Println(p)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// {John 42}
+	// {Jane 23}
+}
+
+func ExampleDecodeAllOf() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	lines := []string{
+		"John 42",
+		"Jane 23",
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	people, err := strum.DecodeAllOf[person](strum.NewDecoder(r))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, p := range people {
+		fmt.Println(p.Name, p.Age)
+	}
+
+	// Output:
+	// John 42
+	// Jane 23
+}
+
 func ExampleDecoder_DecodeAll_ints() {
 	lines := []string{
 		"42",
@@ -132,6 +217,147 @@ func ExampleDecoder_WithTokenRegexp() {
 	// {Blue 36 32}
 }
 
+func ExampleDecoder_WithTokenRegexp_named() {
+	type jeans struct {
+		Color  string
+		Waist  int
+		Inseam int
+	}
+
+	text := "32x36 Blue"
+	r := bytes.NewBufferString(text)
+
+	re := regexp.MustCompile(`^(?P<Inseam>\d+)x(?P<Waist>\d+)\s+(?P<Color>\S+)`)
+	d := strum.NewDecoder(r).WithTokenRegexp(re)
+
+	var j jeans
+	err := d.Decode(&j)
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+
+	fmt.Println(j)
+
+	// Output:
+	// {Blue 36 32}
+}
+
+func ExampleDecoder_WithCSV() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	lines := []string{
+		`"Doe, John",42`,
+		`"Doe, Jane",23`,
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	d := strum.NewDecoder(r).WithCSV(strum.CSVOptions{})
+
+	var people []person
+	err := d.DecodeAll(&people)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, p := range people {
+		fmt.Println(p)
+	}
+
+	// Output:
+	// {Doe, John 42}
+	// {Doe, Jane 23}
+}
+
+func ExampleDecoder_WithQuotedFields() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	text := `"John Smith" 42`
+	r := bytes.NewBufferString(text)
+
+	d := strum.NewDecoder(r).WithQuotedFields()
+
+	var p person
+	err := d.Decode(&p)
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+
+	fmt.Println(p)
+
+	// Output:
+	// {John Smith 42}
+}
+
+func ExampleDecoder_WithFixedWidth() {
+	type account struct {
+		ID      int
+		Name    string
+		Balance float64
+	}
+
+	lines := []string{
+		"   42John Smith   12.50",
+		"   43Jane Doe    100.00",
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	d := strum.NewDecoder(r).WithFixedWidth(
+		strum.Column{Start: 0, Width: 5},
+		strum.Column{Start: 5, Width: 10},
+		strum.Column{Start: 15},
+	)
+
+	var accounts []account
+	err := d.DecodeAll(&accounts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, a := range accounts {
+		fmt.Println(a)
+	}
+
+	// Output:
+	// {42 John Smith 12.5}
+	// {43 Jane Doe 100}
+}
+
+func ExampleDecoder_WithHeader() {
+	type process struct {
+		PID     int
+		Command string `strum:"cmd"`
+	}
+
+	lines := []string{
+		"CMD   PID",
+		"init  1",
+		"sshd  812",
+	}
+
+	r := bytes.NewBufferString(strings.Join(lines, "\n"))
+	d := strum.NewDecoder(r).WithHeader()
+
+	var procs []process
+	err := d.DecodeAll(&procs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, p := range procs {
+		fmt.Println(p)
+	}
+
+	// Output:
+	// {1 init}
+	// {812 sshd}
+}
+
 func ExampleDecoder_WithSplitOn() {
 	type person struct {
 		Last  string
@@ -155,6 +381,53 @@ func ExampleDecoder_WithSplitOn() {
 	// {Doe John}
 }
 
+func ExampleEncoder_EncodeAll() {
+	type person struct {
+		Name   string
+		Age    int
+		Joined time.Time `strum:",layout=2006-01-02"`
+	}
+
+	people := []person{
+		{"John", 42, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"Jane", 23, time.Date(2022, 2, 22, 0, 0, 0, 0, time.UTC)},
+	}
+
+	e := strum.NewEncoder(os.Stdout).WithSeparator(",")
+	err := e.EncodeAll(people)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// John,42,2020-03-01
+	// Jane,23,2022-02-22
+}
+
+func ExampleEncoder_EncodeTable() {
+	type process struct {
+		PID     int
+		Command string `strum:"cmd"`
+		CPU     float64
+	}
+
+	procs := []process{
+		{1, "init", 0.5},
+		{812, "sshd", 12.25},
+	}
+
+	e := strum.NewEncoder(os.Stdout).WithSeparator("  ").WithRightAlignedNumbers()
+	err := e.EncodeTable(procs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// PID  cmd     CPU
+	//   1  init    0.5
+	// 812  sshd  12.25
+}
+
 func Example_synopsis() {
 	var err error
 	d := strum.NewDecoder(os.Stdin)
@@ -0,0 +1,400 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldInfo describes how a token maps to a struct field.
+type fieldInfo struct {
+	index int     // index of the field in the struct
+	name  string  // qualified name for error messages, e.g. "person.Age"
+	key   string  // name for matching named tokens
+	token int     // index of the token decoded into the field
+	col   *Column // fixed-width column from the tag, if any
+	conv  converter
+
+	// A slice field collects the remaining tokens when it is the last field;
+	// conv decodes its elements.
+	variadic bool
+	// A rest field gets the rest of the line, starting from its token.
+	rest bool
+	// A required field must have a token, even if the Decoder isn't strict.
+	required bool
+	// A field with a default decodes def when its token is absent.
+	hasDefault bool
+	def        string
+	// Tokens matching nulls leave the field zeroed.
+	nulls []string
+
+	// Conversion options from the tag, which are also used for encoding.
+	layout string
+	loc    *time.Location
+	base   int
+}
+
+// fieldTag holds the parsed contents of a `strum` struct tag.
+type fieldTag struct {
+	skip     bool
+	name     string
+	token    int // -1 if no explicit token index was given
+	col      *Column
+	rest     bool
+	required bool
+
+	hasDefault bool
+	def        string
+	nulls      []string
+
+	layout string
+	loc    *time.Location
+
+	hasBase bool
+	base    int
+}
+
+// parseTag interprets a `strum` tag of the form "key,option,...".  A tag of
+// "-" skips the field.  A key that is a non-negative integer is an explicit
+// token index and any other non-empty key is a name for matching named
+// tokens.  Options follow the key, separated by commas.  An option value may
+// be enclosed in single quotes to include commas, as in "default='a,b'".
+func parseTag(tag string) (fieldTag, error) {
+	ft := fieldTag{token: -1}
+	if tag == "-" {
+		ft.skip = true
+		return ft, nil
+	}
+
+	key, opts := splitOnce(tag, ",")
+	if key != "" {
+		n, err := strconv.Atoi(key)
+		switch {
+		case err != nil:
+			ft.name = key
+		case n < 0:
+			return ft, fmt.Errorf("invalid token index %q", key)
+		default:
+			ft.token = n
+		}
+	}
+
+	for opts != "" {
+		var name, value string
+		var err error
+		name, value, opts, err = nextTagOption(opts)
+		if err != nil {
+			return ft, err
+		}
+		switch name {
+		case "col":
+			col, err := parseColumn(value)
+			if err != nil {
+				return ft, err
+			}
+			ft.col = &col
+		case "rest":
+			if value != "" {
+				return ft, fmt.Errorf("tag option %q takes no value", name)
+			}
+			ft.rest = true
+		case "required":
+			if value != "" {
+				return ft, fmt.Errorf("tag option %q takes no value", name)
+			}
+			ft.required = true
+		case "default":
+			ft.hasDefault = true
+			ft.def = value
+		case "null":
+			ft.nulls = append(ft.nulls, value)
+		case "layout":
+			ft.layout = value
+		case "tz":
+			loc, err := time.LoadLocation(value)
+			if err != nil {
+				return ft, fmt.Errorf("invalid time zone: %w", err)
+			}
+			ft.loc = loc
+		case "base":
+			base, err := strconv.Atoi(value)
+			if err != nil || base == 1 || base < 0 || base > 36 {
+				return ft, fmt.Errorf("invalid integer base %q", value)
+			}
+			ft.hasBase = true
+			ft.base = base
+		default:
+			return ft, fmt.Errorf("unknown tag option %q", name)
+		}
+	}
+
+	return ft, nil
+}
+
+// nextTagOption parses the first option from a comma-separated list of tag
+// options, returning its name and value and the remaining options.  A value
+// may be enclosed in single quotes, which are removed.
+func nextTagOption(opts string) (string, string, string, error) {
+	i := strings.IndexAny(opts, ",=")
+	if i < 0 || opts[i] == ',' {
+		name, rest := splitOnce(opts, ",")
+		return name, "", rest, nil
+	}
+	name, opts := opts[:i], opts[i+1:]
+
+	if !strings.HasPrefix(opts, "'") {
+		value, rest := splitOnce(opts, ",")
+		return name, value, rest, nil
+	}
+	end := strings.IndexByte(opts[1:], '\'')
+	if end < 0 {
+		return "", "", "", fmt.Errorf("unterminated quote in tag option %q", name)
+	}
+	value, rest := opts[1:end+1], opts[end+2:]
+	if rest != "" && rest[0] != ',' {
+		return "", "", "", fmt.Errorf("unexpected text after quoted value of tag option %q", name)
+	}
+	return name, value, strings.TrimPrefix(rest, ","), nil
+}
+
+// splitOnce splits s around the first instance of sep.  If sep isn't found,
+// it returns s and an empty string.
+func splitOnce(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+	return s, ""
+}
+
+// structFields returns the decodable fields of a struct type in field order.
+// Fields are assigned consecutive token indexes unless a `strum` tag gives an
+// explicit index, in which case the following fields continue counting from
+// there.  Unexported fields and fields tagged with `strum:"-"` are skipped.
+// A field with a slice type is variadic and must map to the highest token
+// index, as must a string field with the `rest` option.
+//
+// If any fields have invalid tags or types that can't be decoded, it returns
+// an error describing all of them.
+func structFields(t reflect.Type, r *registry) ([]fieldInfo, error) {
+	fields := make([]fieldInfo, 0, t.NumField())
+	var problems []string
+	seen := make(map[int]string)
+	next := 0
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldName := t.Name() + "." + sf.Name
+
+		tag, ok := sf.Tag.Lookup("strum")
+		ft, err := parseTag(tag)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("field %s: %v", fieldName, err))
+			continue
+		}
+		if ft.skip {
+			continue
+		}
+
+		// PkgPath is empty for exported fields.  See https://pkg.go.dev/reflect#StructField
+		// In Go 1.17, this is available as `IsExported`.
+		if sf.PkgPath != "" {
+			if ok {
+				problems = append(problems, fmt.Sprintf("cannot decode to unexported field %s", fieldName))
+			}
+			continue
+		}
+
+		convType := sf.Type
+		variadic := false
+		supported := true
+		switch {
+		case isDecodableField(sf.Type, r):
+		case isVariadicField(sf.Type, r):
+			convType = sf.Type.Elem()
+			variadic = true
+		default:
+			problems = append(problems, fmt.Sprintf("field %s: unsupported type %s", fieldName, sf.Type))
+			supported = false
+		}
+		conv, err := tagConverter(convType, ft)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("field %s: %v", fieldName, err))
+		}
+		if conv == nil {
+			conv = newConverter(convType, r)
+		}
+		if supported && ft.hasDefault {
+			err = checkDefault(convType, conv, ft.def)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("field %s: invalid default %q: %v", fieldName, ft.def, err))
+			}
+		}
+		if ft.rest && sf.Type.Kind() != reflect.String {
+			problems = append(problems, fmt.Sprintf("rest field %s must be a string", fieldName))
+		}
+
+		if ft.token >= 0 {
+			next = ft.token
+		}
+		if prev, ok := seen[next]; ok {
+			problems = append(problems, fmt.Sprintf("fields %s and %s both map to token %d", prev, fieldName, next))
+		}
+		seen[next] = fieldName
+
+		key := sf.Name
+		if ft.name != "" {
+			key = ft.name
+		}
+
+		fields = append(fields, fieldInfo{
+			index: i,
+			name:  fieldName,
+			key:   key,
+			token: next,
+			col:   ft.col,
+			conv:  conv,
+
+			variadic: variadic,
+			rest:     ft.rest,
+			required: ft.required,
+
+			hasDefault: ft.hasDefault,
+			def:        ft.def,
+			nulls:      ft.nulls,
+			layout:     ft.layout,
+			loc:        ft.loc,
+			base:       ft.base,
+		})
+		next++
+	}
+
+	last := -1
+	for _, f := range fields {
+		if f.token > last {
+			last = f.token
+		}
+	}
+	for _, f := range fields {
+		switch {
+		case f.token == last:
+		case f.variadic:
+			problems = append(problems, fmt.Sprintf("slice field %s must map to the last token", f.name))
+		case f.rest:
+			problems = append(problems, fmt.Sprintf("rest field %s must map to the last token", f.name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot decode into struct %s: %s", t, strings.Join(problems, "; "))
+	}
+	return fields, nil
+}
+
+// checkDefault decodes a default into a scratch value with a Decoder's default
+// settings, so that an invalid default is found before any input is read.
+func checkDefault(t reflect.Type, conv converter, def string) error {
+	return conv(NewDecoder(nil), reflect.New(t).Elem(), def)
+}
+
+// tagConverter returns a converter for a field type that applies conversion
+// options from the field's tag, or nil if the tag has none.
+func tagConverter(t reflect.Type, ft fieldTag) (converter, error) {
+	switch {
+	case ft.hasBase && (ft.layout != "" || ft.loc != nil):
+		return nil, errors.New("tag option \"base\" can't be combined with time options")
+	case ft.hasBase:
+		return baseTagConverter(t, ft.base)
+	case ft.layout != "" || ft.loc != nil:
+		return timeTagConverter(t, ft.layout, ft.loc)
+	}
+	return nil, nil
+}
+
+// baseTagConverter returns a converter for an integer field type with a base
+// from a tag.
+func baseTagConverter(t reflect.Type, base int) (converter, error) {
+	elem := t
+	if t.Kind() == reflect.Ptr {
+		elem = t.Elem()
+	}
+	if !isIntegerType(elem) {
+		return nil, fmt.Errorf("tag option \"base\" requires an integer field, not %s", t)
+	}
+
+	conv := intBaseConverter(base)
+	if t.Kind() == reflect.Ptr {
+		conv = ptrConverter(conv)
+	}
+	return conv, nil
+}
+
+// timeTagConverter returns a converter for a time.Time field type with a
+// layout and optional location from a tag.
+func timeTagConverter(t reflect.Type, layout string, loc *time.Location) (converter, error) {
+	if layout == "" {
+		return nil, errors.New("tag option \"tz\" requires a layout")
+	}
+	if t != timeType && t != timePtrType {
+		return nil, fmt.Errorf("tag option \"layout\" requires a time.Time field, not %s", t)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	conv := layoutConverter(layout, loc)
+	if t == timePtrType {
+		conv = ptrConverter(conv)
+	}
+	return conv, nil
+}
+
+// mapNamedTokens returns a copy of fields with token indexes assigned by
+// matching field keys to token names, ignoring case.  Fields without a
+// matching name get a token index of -1.  Empty names are ignored, but any
+// other name that doesn't match a field is an error.
+func mapNamedTokens(t reflect.Type, fields []fieldInfo, names []string) ([]fieldInfo, error) {
+	mapped := make([]fieldInfo, len(fields))
+	for i := range fields {
+		mapped[i] = fields[i]
+		mapped[i].token = -1
+	}
+
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		j := findField(mapped, name)
+		if j < 0 {
+			return nil, fmt.Errorf("no field in struct %s matches name %q", t, name)
+		}
+		if mapped[j].token >= 0 {
+			return nil, fmt.Errorf("name %q matches field %s more than once", name, mapped[j].name)
+		}
+		mapped[j].token = i
+	}
+
+	return mapped, nil
+}
+
+// findField returns the index of the field whose key matches name, preferring
+// an exact match over a case-insensitive one, or -1 if none match.
+func findField(fields []fieldInfo, name string) int {
+	found := -1
+	for i, f := range fields {
+		if f.key == name {
+			return i
+		}
+		if found < 0 && strings.EqualFold(f.key, name) {
+			found = i
+		}
+	}
+	return found
+}
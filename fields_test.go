@@ -0,0 +1,623 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestStructTags(t *testing.T) {
+	type reordered struct {
+		Age  int    `strum:"2"`
+		Name string `strum:"0"`
+	}
+
+	type continued struct {
+		First  string
+		Last   string `strum:"2"`
+		Suffix string
+	}
+
+	type skipped struct {
+		Name    string
+		Comment string `strum:"-"`
+		Age     int
+	}
+
+	type private struct {
+		Name  string
+		notes string
+		Age   int
+	}
+
+	type duplicate struct {
+		A string `strum:"1"`
+		B string `strum:"1"`
+	}
+
+	type badTag struct {
+		A string `strum:"-1"`
+	}
+
+	cases := []testcase{
+		{
+			label: "explicit indexes",
+			input: "John ignored 42",
+			want:  func() interface{} { return reordered{Age: 42, Name: "John"} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got reordered
+				err := d.Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "untagged fields continue from explicit index",
+			input: "John Q Public Jr",
+			want:  func() interface{} { return continued{First: "John", Last: "Public", Suffix: "Jr"} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got continued
+				err := d.Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "too many tokens after explicit index",
+			input: "John Q Public Jr extra",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got continued
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "too many tokens for struct strum_test.continued",
+		},
+		{
+			label: "skipped field",
+			input: "John 42",
+			want:  func() interface{} { return skipped{Name: "John", Age: 42} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got skipped
+				err := d.Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "unexported field ignored",
+			input: "John 42",
+			want:  func() interface{} { return private{Name: "John", Age: 42} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got private
+				err := d.Decode(&got)
+				return got, err
+			},
+			normalize: func(v interface{}) interface{} { p := v.(private); return []interface{}{p.Name, p.Age} },
+		},
+		{
+			label: "duplicate index",
+			input: "a b",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got duplicate
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "both map to token 1",
+		},
+		{
+			label: "invalid tag",
+			input: "a",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got badTag
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `invalid token index "-1"`,
+		},
+	}
+
+	testTestCases(t, cases)
+}
+
+func TestVariadicField(t *testing.T) {
+	type host struct {
+		Name string
+		IP   net.IP
+		Tags []string
+	}
+
+	type ports struct {
+		Name  string
+		Ports []uint16
+	}
+
+	type notLast struct {
+		Tags []string
+		Name string
+	}
+
+	type twoSlices struct {
+		A []string
+		B []string
+	}
+
+	decodeHost := func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+		var got host
+		err := d.Decode(&got)
+		return got, err
+	}
+
+	cases := []testcase{
+		{
+			label:  "remaining tokens",
+			input:  "host 10.0.0.1 tag1 tag2 tag3",
+			want:   func() interface{} { return host{"host", net.ParseIP("10.0.0.1"), []string{"tag1", "tag2", "tag3"}} },
+			decode: decodeHost,
+		},
+		{
+			label:  "no remaining tokens",
+			input:  "host 10.0.0.1",
+			want:   func() interface{} { return host{"host", net.ParseIP("10.0.0.1"), nil} },
+			decode: decodeHost,
+		},
+		{
+			label: "converted elements",
+			input: "web 80 443",
+			want:  func() interface{} { return ports{"web", []uint16{80, 443}} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got ports
+				err := d.Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "bad element",
+			input: "web 80 http",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got ports
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `token 2 "http": error decoding to ports.Ports[1]`,
+		},
+		{
+			label: "slice not last",
+			input: "a b",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got notLast
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "slice field notLast.Tags must map to the last token",
+		},
+		{
+			label: "two slices",
+			input: "a b",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got twoSlices
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "slice field twoSlices.A must map to the last token",
+		},
+	}
+
+	testTestCases(t, cases)
+
+	t.Run("header with last column", func(t *testing.T) {
+		input := "name ip tags\nhost 10.0.0.1 tag1 tag2\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithHeader()
+		var got []host
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []host{{"host", net.ParseIP("10.0.0.1"), []string{"tag1", "tag2"}}}, got, "decoded hosts")
+	})
+
+	t.Run("header with other column", func(t *testing.T) {
+		input := "tags name ip\ntag1 host 10.0.0.1\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithHeader()
+		var got []host
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []host{{"host", net.ParseIP("10.0.0.1"), []string{"tag1"}}}, got, "decoded hosts")
+	})
+}
+
+func TestRestField(t *testing.T) {
+	type commit struct {
+		Hash    string
+		Subject string `strum:",rest"`
+	}
+
+	type notString struct {
+		Name  string
+		Count int `strum:",rest"`
+	}
+
+	type notLast struct {
+		Subject string `strum:",rest"`
+		Hash    string
+	}
+
+	decodeCommit := func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+		var got commit
+		err := d.Decode(&got)
+		return got, err
+	}
+
+	cases := []testcase{
+		{
+			label:  "whitespace preserved",
+			input:  "abc123 Fix  the\tbug ",
+			want:   func() interface{} { return commit{"abc123", "Fix  the\tbug "} },
+			decode: decodeCommit,
+		},
+		{
+			label:  "single token",
+			input:  "  abc123   Fix",
+			want:   func() interface{} { return commit{"abc123", "Fix"} },
+			decode: decodeCommit,
+		},
+		{
+			label:  "no rest",
+			input:  "abc123",
+			want:   func() interface{} { return commit{Hash: "abc123"} },
+			decode: decodeCommit,
+		},
+		{
+			label: "not a string",
+			input: "a 1",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got notString
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "rest field notString.Count must be a string",
+		},
+		{
+			label: "not last",
+			input: "a b",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got notLast
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "rest field notLast.Subject must map to the last token",
+		},
+	}
+
+	testTestCases(t, cases)
+
+	tokenizers := []struct {
+		label string
+		input string
+		setup func(d *strum.Decoder)
+	}{
+		{"split on", "abc123|Fix | the bug", func(d *strum.Decoder) { d.WithSplitOn("|") }},
+		{"quoted fields", `'abc123' Fix | the bug`, func(d *strum.Decoder) { d.WithQuotedFields() }},
+		{"CSV", `"abc123",Fix | the bug`, func(d *strum.Decoder) { d.WithCSV(strum.CSVOptions{}) }},
+		{"fixed width", "abc123 Fix | the bug", func(d *strum.Decoder) {
+			d.WithFixedWidth(strum.Column{Start: 0, Width: 6}, strum.Column{Start: 7})
+		}},
+		{"regexp", "abc123: Fix | the bug", func(d *strum.Decoder) {
+			d.WithTokenRegexp(regexp.MustCompile(`^(\w+): (\w+)`))
+		}},
+		{"named regexp", "abc123: Fix | the bug", func(d *strum.Decoder) {
+			d.WithTokenRegexp(regexp.MustCompile(`^(?P<hash>\w+): (?P<subject>\w+)`))
+		}},
+	}
+
+	for _, c := range tokenizers {
+		d := strum.NewDecoder(bytes.NewBufferString(c.input))
+		c.setup(d)
+		var got commit
+		err := d.Decode(&got)
+		if err != nil {
+			t.Errorf("%s: decode error: %v", c.label, err)
+			continue
+		}
+		want := commit{"abc123", "Fix | the bug"}
+		isWantGot(t, want, got, c.label)
+	}
+
+	t.Run("custom tokenizer", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("abc123 Fix the bug")).WithTokenizer(
+			func(s string) ([]string, error) { return strings.Fields(s), nil },
+		)
+		var got commit
+		err := d.Decode(&got)
+		errContains(t, err, "rest fields require a tokenizer that reports token positions", "custom tokenizer")
+	})
+}
+
+func TestRequiredField(t *testing.T) {
+	type host struct {
+		Name string `strum:",required"`
+		Port int    `strum:",required"`
+		Tags []string
+	}
+
+	type tagged struct {
+		Name string
+		Tags []string `strum:",required"`
+	}
+
+	decodeHost := func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+		var got host
+		err := d.Decode(&got)
+		return got, err
+	}
+
+	cases := []testcase{
+		{
+			label:  "present",
+			input:  "web 80",
+			want:   func() interface{} { return host{Name: "web", Port: 80} },
+			decode: decodeHost,
+		},
+		{
+			label:       "missing",
+			input:       "web",
+			decode:      decodeHost,
+			errContains: "missing tokens for fields host.Port",
+		},
+		{
+			label: "missing slice",
+			input: "web",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got tagged
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "missing tokens for fields tagged.Tags",
+		},
+		{
+			label: "invalid option",
+			input: "web",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					Name string `strum:",required=yes"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `tag option "required" takes no value`,
+		},
+	}
+
+	testTestCases(t, cases)
+}
+
+func TestDefaultField(t *testing.T) {
+	type conn struct {
+		Host    string
+		Port    int           `strum:",default=22"`
+		Timeout time.Duration `strum:",default=30s"`
+		Note    string        `strum:",default='a, b'"`
+	}
+
+	type short struct {
+		Host string
+		Port int `strum:",default=22"`
+		Note string
+	}
+
+	type strict struct {
+		Host string
+		Port int `strum:",required,default=22"`
+	}
+
+	type badDefault struct {
+		Host string
+		Port int `strum:",default=ssh"`
+	}
+
+	decodeConn := func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+		var got conn
+		err := d.Decode(&got)
+		return got, err
+	}
+
+	cases := []testcase{
+		{
+			label:  "all present",
+			input:  "example.com 2222 5s hi",
+			want:   func() interface{} { return conn{"example.com", 2222, 5 * time.Second, "hi"} },
+			decode: decodeConn,
+		},
+		{
+			label:  "defaults",
+			input:  "example.com",
+			want:   func() interface{} { return conn{"example.com", 22, 30 * time.Second, "a, b"} },
+			decode: decodeConn,
+		},
+		{
+			label: "empty named token",
+			input: "example.com,,5s,",
+			want:  func() interface{} { return conn{"example.com", 22, 5 * time.Second, "a, b"} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got conn
+				re := regexp.MustCompile(`^(?P<host>[^,]*),(?P<port>[^,]*),(?P<timeout>[^,]*),(?P<note>[^,]*)$`)
+				err := d.WithTokenRegexp(re).Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "empty split token",
+			input: "example.com,,5s",
+			want:  func() interface{} { return conn{"example.com", 22, 5 * time.Second, "a, b"} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got conn
+				err := d.WithSplitOn(",").Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "empty CSV token",
+			input: "a,,c",
+			want:  func() interface{} { return short{"a", 22, "c"} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got short
+				err := d.WithCSV(strum.CSVOptions{}).Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "default satisfies required",
+			input: "example.com",
+			want:  func() interface{} { return strict{"example.com", 22} },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got strict
+				err := d.WithStrict().Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "bad default",
+			input: "example.com 22",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got badDefault
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `field badDefault.Port: invalid default "ssh"`,
+		},
+		{
+			label: "unterminated quote",
+			input: "example.com",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					Note string `strum:",default='a,b"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `unterminated quote in tag option "default"`,
+		},
+	}
+
+	testTestCases(t, cases)
+
+	t.Run("bad default doesn't consume input", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("example.com 2222\n"))
+		var bad badDefault
+		err := d.Decode(&bad)
+		errContains(t, err, "invalid default", "decoding")
+
+		var got conn
+		err = d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, conn{"example.com", 2222, 30 * time.Second, "a, b"}, got, "decoded after error")
+	})
+}
+
+func TestTimeLayoutField(t *testing.T) {
+	type event struct {
+		Day     time.Time  `strum:",layout=2006-01-02"`
+		Local   *time.Time `strum:",layout='Jan 2, 2006 15:04',tz=America/New_York"`
+		Default time.Time
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	local := time.Date(2021, 3, 4, 15, 30, 0, 0, newYork)
+
+	cases := []testcase{
+		{
+			label: "layouts and default parser",
+			input: "2021-03-04|Mar 4, 2021 15:30|2021-03-04T05:06:07Z",
+			want: func() interface{} {
+				return event{
+					time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+					&local,
+					time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+				}
+			},
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got event
+				err := d.WithSplitOn("|").Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "time zone",
+			input: "2021-03-04T10:00",
+			want:  func() interface{} { return time.Date(2021, 3, 4, 10, 0, 0, 0, paris) },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					When time.Time `strum:",layout=2006-01-02T15:04,tz=Europe/Paris"`
+				}
+				err := d.Decode(&got)
+				return got.When, err
+			},
+		},
+		{
+			label: "layout mismatch",
+			input: "03/04/2021",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got event
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `token 0 "03/04/2021": error decoding to event.Day`,
+		},
+		{
+			label: "not a time",
+			input: "x",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					Day string `strum:",layout=2006-01-02"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `tag option "layout" requires a time.Time field, not string`,
+		},
+		{
+			label: "tz without layout",
+			input: "x",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					Day time.Time `strum:",tz=UTC"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `tag option "tz" requires a layout`,
+		},
+		{
+			label: "unknown time zone",
+			input: "x",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					Day time.Time `strum:",layout=2006-01-02,tz=Nowhere/Special"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: "invalid time zone",
+		},
+	}
+
+	testTestCases(t, cases)
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Column describes the position of a fixed-width column within a line for
+// `WithFixedWidth`.  Positions are offsets in runes, starting from zero.
+type Column struct {
+	// Start is the offset of the first rune of the column.
+	Start int
+	// Width is the number of runes in the column.  If zero, the column ends
+	// at End instead.
+	Width int
+	// End is the offset just past the last rune of the column.  If zero and
+	// Width is also zero, the column extends to the end of the line.
+	End int
+	// NoTrim preserves leading and trailing whitespace in the column, which
+	// is removed by default.
+	NoTrim bool
+}
+
+func (c Column) validate() error {
+	switch {
+	case c.Start < 0:
+		return fmt.Errorf("column start %d is negative", c.Start)
+	case c.Width < 0:
+		return fmt.Errorf("column width %d is negative", c.Width)
+	case c.Width == 0 && c.End != 0 && c.End <= c.Start:
+		return fmt.Errorf("column end %d is not after start %d", c.End, c.Start)
+	}
+	return nil
+}
+
+// parseColumn parses a column from a struct tag option of the form
+// "start:end" or "start:", where the latter extends to the end of the line.
+func parseColumn(s string) (Column, error) {
+	start, end := splitOnce(s, ":")
+	if !strings.Contains(s, ":") {
+		return Column{}, fmt.Errorf("invalid column %q: must be start:end", s)
+	}
+
+	var c Column
+	var err error
+	c.Start, err = strconv.Atoi(start)
+	if err != nil {
+		return Column{}, fmt.Errorf("invalid column %q: %w", s, err)
+	}
+	if end != "" {
+		c.End, err = strconv.Atoi(end)
+		if err != nil {
+			return Column{}, fmt.Errorf("invalid column %q: %w", s, err)
+		}
+	}
+
+	err = c.validate()
+	if err != nil {
+		return Column{}, fmt.Errorf("invalid column %q: %w", s, err)
+	}
+	return c, nil
+}
+
+// cutColumns extracts a token for each column of a line, along with the byte
+// offset where each column starts.  Trailing columns that start at or past the
+// end of the line produce no tokens; other such columns produce empty tokens
+// with an offset of -1.
+func cutColumns(s string, cols []Column) ([]string, []int) {
+	// bounds[i] is the byte offset of rune i; the last entry is len(s).
+	bounds := make([]int, 0, len(s)+1)
+	for i := range s {
+		bounds = append(bounds, i)
+	}
+	bounds = append(bounds, len(s))
+	numRunes := len(bounds) - 1
+
+	n := len(cols)
+	for n > 0 && cols[n-1].Start >= numRunes {
+		n--
+	}
+
+	tokens := make([]string, n)
+	offsets := make([]int, n)
+	for i, c := range cols[:n] {
+		if c.Start >= numRunes {
+			offsets[i] = -1
+			continue
+		}
+
+		end := numRunes
+		switch {
+		case c.Width > 0:
+			end = c.Start + c.Width
+		case c.End > 0:
+			end = c.End
+		}
+		if end > numRunes {
+			end = numRunes
+		}
+
+		tok := s[bounds[c.Start]:bounds[end]]
+		if !c.NoTrim {
+			tok = strings.TrimSpace(tok)
+		}
+		tokens[i] = tok
+		offsets[i] = bounds[c.Start]
+	}
+
+	return tokens, offsets
+}
+
+// WithFixedWidth modifies a Decoder to extract tokens from fixed-width
+// columns.  Each column produces one token, in the order given.  When decoding
+// into a struct, an empty column leaves its field zeroed.
+//
+// If no columns are given, columns are taken from struct tags when decoding
+// into a struct, using a `col` option of the form "start:end", or "start:"
+// for a column extending to the end of the line, such as `strum:",col=0:10"`.
+// Fields without a `col` option are not decoded.  Decoding into any other
+// type is an error.
+func (d *Decoder) WithFixedWidth(cols ...Column) *Decoder {
+	var colsErr error
+	for _, c := range cols {
+		if err := c.validate(); err != nil {
+			colsErr = err
+			break
+		}
+	}
+	cols = append([]Column(nil), cols...)
+
+	d.withSpanTokenizer(
+		func(s string) ([]string, []int, error) {
+			if len(cols) == 0 {
+				return nil, nil, errors.New("fixed-width columns are only defined by struct tags")
+			}
+			if colsErr != nil {
+				return nil, nil, fmt.Errorf("invalid fixed-width column: %w", colsErr)
+			}
+			tokens, offsets := cutColumns(s, cols)
+			return tokens, offsets, nil
+		},
+	)
+	d.fixedTags = len(cols) == 0
+	d.emptyAbsent = true
+	return d
+}
+
+// fixedWidthTokens reads a line and extracts tokens from fixed-width columns
+// defined by struct tags.  It returns the line and token offsets like
+// `tokenSpans`.
+func (d *Decoder) fixedWidthTokens(cols []Column) (string, []string, []int, error) {
+	s, err := d.readline()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	tokens, offsets := cutColumns(s, cols)
+	return s, tokens, offsets, nil
+}
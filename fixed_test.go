@@ -0,0 +1,132 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestFixedWidthTokens(t *testing.T) {
+	cases := []struct {
+		label       string
+		cols        []strum.Column
+		input       string
+		want        []string
+		errContains string
+	}{
+		{
+			label: "width and end",
+			cols:  []strum.Column{{Start: 0, Width: 6}, {Start: 6, End: 9}, {Start: 9}},
+			input: "John   42 rest of line ",
+			want:  []string{"John", "42", "rest of line"},
+		},
+		{
+			label: "no trim",
+			cols:  []strum.Column{{Start: 0, Width: 6, NoTrim: true}, {Start: 6, End: 9}},
+			input: "John   42",
+			want:  []string{"John  ", "42"},
+		},
+		{
+			label: "runes, not bytes",
+			cols:  []strum.Column{{Start: 0, Width: 5}, {Start: 5, Width: 3}},
+			input: "José 42",
+			want:  []string{"José", "42"},
+		},
+		{
+			label: "short line",
+			cols:  []strum.Column{{Start: 0, Width: 6}, {Start: 6, Width: 3}, {Start: 9, Width: 3}},
+			input: "John   4",
+			want:  []string{"John", "4"},
+		},
+		{
+			label: "short line, columns out of order",
+			cols:  []strum.Column{{Start: 0, Width: 3}, {Start: 40, Width: 5}, {Start: 3, Width: 2}},
+			input: "abcdefghij",
+			want:  []string{"abc", "", "de"},
+		},
+		{
+			label:       "invalid column",
+			cols:        []strum.Column{{Start: 5, End: 2}},
+			input:       "John",
+			errContains: "column end 2 is not after start 5",
+		},
+		{
+			label:       "no columns",
+			input:       "John",
+			errContains: "only defined by struct tags",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithFixedWidth(c.cols...)
+			got, err := d.Tokens()
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "tokenizing")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, got, "tokens")
+		})
+	}
+}
+
+func TestFixedWidthTags(t *testing.T) {
+	type account struct {
+		ID      int    `strum:",col=0:5"`
+		Name    string `strum:",col=5:15"`
+		Note    string
+		Balance float64 `strum:",col=15:"`
+	}
+
+	type badColumn struct {
+		ID int `strum:",col=5"`
+	}
+
+	lines := "   42John Smith   12.50\n   43Jane         \n"
+
+	d := strum.NewDecoder(bytes.NewBufferString(lines)).WithFixedWidth()
+	var got []account
+	err := d.DecodeAll(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []account{
+		{ID: 42, Name: "John Smith", Balance: 12.5},
+		{ID: 43, Name: "Jane"},
+	}
+	isWantGot(t, want, got, "decoded accounts")
+
+	type outOfOrder struct {
+		A string `strum:",col=0:3"`
+		B string `strum:",col=40:45"`
+		C string `strum:",col=3:5"`
+	}
+
+	d = strum.NewDecoder(bytes.NewBufferString("abcdefghij\n")).WithFixedWidth()
+	var ooo outOfOrder
+	err = d.Decode(&ooo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, outOfOrder{A: "abc", C: "de"}, ooo, "out of order columns")
+
+	d = strum.NewDecoder(bytes.NewBufferString(lines)).WithFixedWidth()
+	var bad badColumn
+	err = d.Decode(&bad)
+	errContains(t, err, `invalid column "5": must be start:end`, "bad column tag")
+
+	d = strum.NewDecoder(bytes.NewBufferString(lines)).WithFixedWidth()
+	var xs []string
+	err = d.Decode(&xs)
+	errContains(t, err, "only defined by struct tags", "non-struct with tag columns")
+}
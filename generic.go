@@ -0,0 +1,88 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// A TypedDecoder decodes lines of input into values of type T.  Unlike
+// `Decoder`, it checks that T can be decoded when it is created, so decoding
+// only fails because of the input.
+type TypedDecoder[T any] struct {
+	d *Decoder
+}
+
+// NewTypedDecoder returns a TypedDecoder that reads with a Decoder, including
+// any tokenizer and other configuration of the Decoder.  It returns an error
+// if T can't be decoded.
+func NewTypedDecoder[T any](d *Decoder) (*TypedDecoder[T], error) {
+	err := d.reg.planFor(reflect.TypeOf((*T)(nil)).Elem()).err
+	if err != nil {
+		return nil, fmt.Errorf("NewTypedDecoder: %w", err)
+	}
+	return &TypedDecoder[T]{d: d}, nil
+}
+
+// Decoder returns the underlying Decoder.
+func (td *TypedDecoder[T]) Decoder() *Decoder {
+	return td.d
+}
+
+// Decode reads the next line of input and returns it as a T.  It returns
+// `io.EOF` when no more data is available.  Errors decoding a line are
+// returned as a `*DecodeError`.
+func (td *TypedDecoder[T]) Decode() (T, error) {
+	var v T
+	err := td.d.decodeRecord(reflect.ValueOf(&v).Elem())
+	return v, err
+}
+
+// DecodeAll reads the remaining lines of input and returns them as a slice
+// of T.  It returns the values decoded before any error.  It returns a `nil`
+// error when EOF is reached.
+func (td *TypedDecoder[T]) DecodeAll() ([]T, error) {
+	xs := make([]T, 0)
+	err := td.d.decodeAll(reflect.ValueOf(&xs).Elem())
+	return xs, err
+}
+
+// DecodeAllOf reads the remaining lines of input from a Decoder and returns
+// them as a slice of T.  It works like `DecodeAll`, except that it checks
+// that T can be decoded before reading input.
+func DecodeAllOf[T any](d *Decoder) ([]T, error) {
+	td, err := NewTypedDecoder[T](d)
+	if err != nil {
+		return nil, err
+	}
+	return td.DecodeAll()
+}
+
+// RegisterConverterOf modifies a Decoder to convert tokens into values of
+// type T with a custom function.  It works like `RegisterConverter`, but
+// with a function that returns a T.
+func RegisterConverterOf[T any](d *Decoder, fn func(s string) (T, error)) *Decoder {
+	return d.RegisterConverter(
+		reflect.TypeOf((*T)(nil)).Elem(),
+		func(s string) (interface{}, error) {
+			v, err := fn(s)
+			if err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	)
+}
+
+// UnmarshalAs parses the input data as newline delimited strings and returns
+// them as a slice of T.  It works like `Unmarshal`, except that it checks
+// that T can be decoded before parsing the input.
+func UnmarshalAs[T any](data []byte) ([]T, error) {
+	return DecodeAllOf[T](NewDecoder(bytes.NewBuffer(data)))
+}
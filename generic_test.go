@@ -0,0 +1,123 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestTypedDecoder(t *testing.T) {
+	type person struct {
+		Name string
+		Age  *int
+	}
+
+	d := strum.NewDecoder(bytes.NewBufferString("John 42\nJane 23\nJack 36\n"))
+	td, err := strum.NewTypedDecoder[person](d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.Decoder() != d {
+		t.Error("expected Decoder to return the underlying decoder")
+	}
+
+	p, err := td.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, "John", p.Name, "first record name")
+	isWantGot(t, 42, *p.Age, "first record age")
+
+	rest, err := td.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, 2, len(rest), "remaining records")
+
+	_, err = td.Decode()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestTypedDecoderValidation(t *testing.T) {
+	type nested struct {
+		Inner struct{ A int }
+	}
+	type badTag struct {
+		A int `strum:"-1"`
+	}
+
+	check := func(t *testing.T, err error, contains string) {
+		t.Helper()
+		errContains(t, err, contains, "NewTypedDecoder")
+	}
+
+	d := strum.NewDecoder(bytes.NewBufferString(""))
+
+	_, err := strum.NewTypedDecoder[complex128](d)
+	check(t, err, "cannot decode into type complex128")
+
+	_, err = strum.NewTypedDecoder[map[string]int](d)
+	check(t, err, "cannot decode into type map[string]int")
+
+	_, err = strum.NewTypedDecoder[[]struct{ A int }](d)
+	check(t, err, "decoding to this slice type not supported")
+
+	_, err = strum.NewTypedDecoder[nested](d)
+	check(t, err, "field nested.Inner: unsupported type struct { A int }")
+
+	_, err = strum.NewTypedDecoder[*badTag](d)
+	check(t, err, "invalid token index")
+
+	// Valid types
+	_, err = strum.NewTypedDecoder[string](d)
+	check(t, err, "")
+	_, err = strum.NewTypedDecoder[**int](d)
+	check(t, err, "")
+	_, err = strum.NewTypedDecoder[[]time.Duration](d)
+	check(t, err, "")
+	_, err = strum.NewTypedDecoder[*big.Rat](d)
+	check(t, err, "")
+	_, err = strum.NewTypedDecoder[struct {
+		T *time.Time
+		R *big.Rat
+		B **bool
+	}](d)
+	check(t, err, "")
+}
+
+func TestDecodeAllOf(t *testing.T) {
+	d := strum.NewDecoder(bytes.NewBufferString("1 2\n3\n"))
+	got, err := strum.DecodeAllOf[[]int](d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, [][]int{{1, 2}, {3}}, got, "decoded slices")
+
+	d = strum.NewDecoder(bytes.NewBufferString("1\n"))
+	_, err = strum.DecodeAllOf[complex64](d)
+	errContains(t, err, "cannot decode into type complex64", "invalid type")
+}
+
+func TestUnmarshalAs(t *testing.T) {
+	got, err := strum.UnmarshalAs[int]([]byte("1\n2\n3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, []int{1, 2, 3}, got, "unmarshaled ints")
+
+	got, err = strum.UnmarshalAs[int]([]byte("1\nx\n3"))
+	errContains(t, err, "line 2:", "bad input")
+	isWantGot(t, []int{1}, got, "values before error")
+}
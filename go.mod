@@ -1,6 +1,6 @@
 module github.com/xdg-go/strum
 
-go 1.16
+go 1.18
 
 require (
 	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de
@@ -0,0 +1,88 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Next reads the next line of input for decoding with `Scan`, in the style
+// of `database/sql.Rows`.  It returns false when no more data is available or
+// if an error occurs reading input, which is reported by `Err`.
+func (d *Decoder) Next() bool {
+	d.hasRow = false
+	if d.iterErr != nil {
+		return false
+	}
+
+	d.recLine, d.rec = 0, ""
+	s, err := d.readline()
+	if err != nil {
+		if err != io.EOF {
+			d.iterErr = d.lineError(err)
+		}
+		return false
+	}
+
+	d.row, d.rowLine, d.hasRow = s, d.recLine, true
+	return true
+}
+
+// Scan decodes the line read by the most recent call to `Next` into the value
+// pointed to by `v`.  It may be called more than once for the same line.
+// Errors decoding the line are returned as a `*DecodeError`.
+func (d *Decoder) Scan(v interface{}) error {
+	destValue, err := extractDestValue(v)
+	if err != nil {
+		return fmt.Errorf("Scan: %w", err)
+	}
+	if !d.hasRow {
+		return errors.New("Scan called without a successful call to Next")
+	}
+
+	d.recLine, d.rec = d.rowLine, d.row
+	d.buffered = true
+	err = d.lineError(d.decode(destValue))
+	d.buffered = false
+	return err
+}
+
+// Err returns the error, if any, that stopped `Next`.  Reaching the end of
+// input is not an error.
+func (d *Decoder) Err() error {
+	return d.iterErr
+}
+
+// DecodeEach decodes each remaining line of input into the value pointed to
+// by `v` and then calls `fn`, without collecting values like `DecodeAll`.
+// The value is zeroed before each line is decoded.  DecodeEach stops and
+// returns the first error from decoding or from `fn`.  It returns `nil` when
+// EOF is reached.
+func (d *Decoder) DecodeEach(v interface{}, fn func() error) error {
+	destValue, err := extractDestValue(v)
+	if err != nil {
+		return fmt.Errorf("DecodeEach: %w", err)
+	}
+
+	for {
+		destValue.Set(reflect.Zero(destValue.Type()))
+		err := d.decodeRecord(destValue)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		err = fn()
+		if err != nil {
+			return err
+		}
+	}
+}
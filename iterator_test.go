@@ -0,0 +1,160 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestIterator(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	t.Run("scan rows", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("John 42\nJane 23\n"))
+		var got []person
+		var lines []string
+		for d.Next() {
+			var p person
+			err := d.Scan(&p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, p)
+
+			// Scanning the same row again decodes it differently.
+			var line string
+			err = d.Scan(&line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, line)
+		}
+		if d.Err() != nil {
+			t.Fatal(d.Err())
+		}
+		isWantGot(t, []person{{"John", 42}, {"Jane", 23}}, got, "scanned records")
+		isWantGot(t, []string{"John 42", "Jane 23"}, lines, "scanned lines")
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("John 42\nJane old\n"))
+		var errs []error
+		for d.Next() {
+			var p person
+			err := d.Scan(&p)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		isWantGot(t, 1, len(errs), "number of scan errors")
+		var de *strum.DecodeError
+		if !errors.As(errs[0], &de) {
+			t.Fatalf("expected *strum.DecodeError, got %T", errs[0])
+		}
+		isWantGot(t, 2, de.Line, "error line")
+		if d.Err() != nil {
+			t.Errorf("expected no iteration error, got %v", d.Err())
+		}
+	})
+
+	t.Run("scan without next", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("John 42\n"))
+		var p person
+		err := d.Scan(&p)
+		errContains(t, err, "Scan called without a successful call to Next", "scan before next")
+		for d.Next() {
+		}
+		err = d.Scan(&p)
+		errContains(t, err, "Scan called without a successful call to Next", "scan after end")
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		d := strum.NewDecoder(failingReader{})
+		if d.Next() {
+			t.Fatal("expected Next to fail")
+		}
+		errContains(t, d.Err(), "read failed", "iteration error")
+		if d.Next() {
+			t.Fatal("expected Next to keep failing")
+		}
+	})
+
+	t.Run("with header", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("age name\n42 John\n")).WithHeader()
+		var got []person
+		for d.Next() {
+			var p person
+			err := d.Scan(&p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, p)
+		}
+		isWantGot(t, []person{{"John", 42}}, got, "scanned records")
+	})
+}
+
+func TestDecodeEach(t *testing.T) {
+	t.Run("all lines", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1 2\n3\n"))
+		var xs []int
+		var got [][]int
+		err := d.DecodeEach(&xs, func() error {
+			got = append(got, xs)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, [][]int{{1, 2}, {3}}, got, "decoded lines")
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1\n2\n3\n"))
+		stop := errors.New("stop")
+		var x int
+		var got []int
+		err := d.DecodeEach(&x, func() error {
+			got = append(got, x)
+			if x == 2 {
+				return stop
+			}
+			return nil
+		})
+		if err != stop {
+			t.Fatalf("expected callback error, got %v", err)
+		}
+		isWantGot(t, []int{1, 2}, got, "decoded lines")
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1\nx\n3\n"))
+		var x int
+		err := d.DecodeEach(&x, func() error { return nil })
+		errContains(t, err, "line 2:", "decode error")
+	})
+
+	t.Run("non-pointer", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1\n"))
+		var x int
+		err := d.DecodeEach(x, func() error { return nil })
+		errContains(t, err, "DecodeEach: argument must be a pointer", "non-pointer")
+	})
+}
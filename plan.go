@@ -0,0 +1,180 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type planKind int
+
+const (
+	planInvalid planKind = iota
+	planToken            // a single token
+	planLine             // an entire line
+	planStruct           // tokens mapped to struct fields
+	planSlice            // tokens appended to a slice
+	planPtr              // a pointer to another plan
+)
+
+// A decodePlan holds the analysis of a destination type, so that decoding
+// doesn't repeat type analysis or method lookup for every line.  Plans don't
+// depend on Decoder configuration other than custom converters, so plans
+// without custom converters are cached by type and shared by all Decoders.
+type decodePlan struct {
+	kind planKind
+	conv converter   // for planToken, planLine, or elements of planSlice
+	elem *decodePlan // for planPtr
+	err  error       // if the type, or any type it contains, can't be decoded
+
+	// For planStruct
+	fields    []fieldInfo
+	numTokens int         // one past the highest mapped token index
+	variadic  bool        // whether the last field collects remaining tokens
+	rest      bool        // whether the last field needs token offsets
+	colFields []fieldInfo // fields mapped to fixed-width columns from tags
+	cols      []Column
+}
+
+// leaf returns the plan at the end of a chain of planPtr plans.
+func (p *decodePlan) leaf() *decodePlan {
+	for p.kind == planPtr {
+		p = p.elem
+	}
+	return p
+}
+
+var planCache sync.Map // map[reflect.Type]*decodePlan
+
+// planFor returns the cached decoding plan for a type, creating it if needed.
+func planFor(t reflect.Type) *decodePlan {
+	if p, ok := planCache.Load(t); ok {
+		return p.(*decodePlan)
+	}
+	p, _ := planCache.LoadOrStore(t, newPlan(t, nil))
+	return p.(*decodePlan)
+}
+
+// newPlan analyzes a type.  It determines whether a line must have a single
+// token, or be consumed as a line, or whether multiple tokens are decoded to a
+// slice or struct.  Pointers get a plan for their element, in case they are
+// pointers to structs, slices, or text unmarshalers.  Custom converters from
+// the registry take precedence.
+func newPlan(t reflect.Type, r *registry) *decodePlan {
+	// Handle certain types specially, not as their underlying data kind.
+	switch {
+	case r.converter(t) != nil:
+		return &decodePlan{kind: planToken, conv: newConverter(t, r)}
+	case t == durationType, t == timeType, t == timePtrType:
+		return &decodePlan{kind: planToken, conv: newConverter(t, r)}
+	case t.Implements(textUnmarshalerType), reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &decodePlan{kind: planToken, conv: newConverter(t, r)}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return &decodePlan{kind: planToken, conv: newConverter(t, r)}
+	case reflect.String:
+		return &decodePlan{kind: planLine, conv: newConverter(t, r)}
+	case reflect.Struct:
+		return newStructPlan(t, r)
+	case reflect.Slice:
+		if !isDecodableValue(reflect.New(t.Elem()).Elem(), r) {
+			return &decodePlan{err: fmt.Errorf("decoding to this slice type not supported: %s", t)}
+		}
+		return &decodePlan{kind: planSlice, conv: newConverter(t.Elem(), r)}
+	case reflect.Ptr:
+		elem := r.planFor(t.Elem())
+		return &decodePlan{kind: planPtr, elem: elem, err: elem.err}
+	default:
+		return &decodePlan{err: fmt.Errorf("cannot decode into type %s", t)}
+	}
+}
+
+func newStructPlan(t reflect.Type, r *registry) *decodePlan {
+	fields, err := structFields(t, r)
+	if err != nil {
+		return &decodePlan{kind: planStruct, err: err}
+	}
+
+	p := &decodePlan{kind: planStruct, fields: fields}
+	for _, f := range fields {
+		if f.token >= p.numTokens {
+			p.numTokens = f.token + 1
+		}
+		if f.variadic || f.rest {
+			p.variadic = true
+		}
+		if f.rest {
+			p.rest = true
+		}
+		if f.col != nil {
+			cf := f
+			cf.token = len(p.cols)
+			p.colFields = append(p.colFields, cf)
+			p.cols = append(p.cols, *f.col)
+		}
+	}
+
+	return p
+}
+
+// namedPlan holds struct fields mapped to token names, or an error if the
+// names can't be mapped.
+type namedPlan struct {
+	fields   []fieldInfo
+	variadic bool // whether the last name maps to a variadic or rest field
+	err      error
+}
+
+// namedFields returns the fields of a struct plan mapped to the Decoder's
+// current token names.  Mappings are cached on the Decoder, since token names
+// are fixed for a given tokenizer or header.
+func (d *Decoder) namedFields(t reflect.Type, p *decodePlan, names []string) namedPlan {
+	if np, ok := d.named[t]; ok {
+		return np
+	}
+
+	var np namedPlan
+	np.fields, np.err = mapNamedTokens(t, p.fields, names)
+	if np.err == nil && d.columns != nil {
+		np.err = d.checkColumns(np.fields)
+	}
+	for _, f := range np.fields {
+		if (f.variadic || f.rest) && f.token == len(names)-1 {
+			np.variadic = true
+		}
+	}
+
+	if d.named == nil {
+		d.named = make(map[reflect.Type]namedPlan)
+	}
+	d.named[t] = np
+
+	return np
+}
+
+// checkColumns ensures that fields requiring tokens were mapped to a header
+// column.
+func (d *Decoder) checkColumns(fields []fieldInfo) error {
+	var missing []string
+	for _, f := range fields {
+		if f.token < 0 && d.needsToken(f) {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no header column for fields %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xdg-go/strum"
+)
+
+type benchRecord struct {
+	Host    string
+	Port    int
+	Up      bool
+	Latency time.Duration
+	Load    float64
+}
+
+func benchInput(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString("example.com 443 true 15ms 0.75\n")
+	}
+	return b.String()
+}
+
+// Plans are shared by all Decoders, so concurrent decoding of the same types
+// must be safe.  Run with -race.
+func TestPlansConcurrent(t *testing.T) {
+	input := benchInput(100)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := strum.NewDecoder(strings.NewReader(input))
+			var got []benchRecord
+			err := d.DecodeAll(&got)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(got) != 100 || got[99].Latency != 15*time.Millisecond {
+				t.Errorf("unexpected result: %d records, last %v", len(got), got[len(got)-1])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSliceErrorKeepsPriorElements(t *testing.T) {
+	d := strum.NewDecoder(bytes.NewBufferString("3 4 x 5"))
+	xs := []int{1, 2}
+	err := d.Decode(&xs)
+	errContains(t, err, `token 2 "x"`, "bad element")
+	isWantGot(t, []int{1, 2, 3, 4}, xs, "slice after error")
+}
+
+func BenchmarkDecodeStruct(b *testing.B) {
+	input := benchInput(1000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d := strum.NewDecoder(strings.NewReader(input))
+		var r benchRecord
+		for d.Decode(&r) == nil {
+		}
+	}
+}
+
+func BenchmarkDecodeSlice(b *testing.B) {
+	input := strings.Repeat("1 2 3 4 5 6 7 8\n", 1000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d := strum.NewDecoder(strings.NewReader(input))
+		for {
+			var xs []int
+			if d.Decode(&xs) != nil {
+				break
+			}
+		}
+	}
+}
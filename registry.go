@@ -0,0 +1,90 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A ConverterFunc converts a token into a value for a type registered with
+// `RegisterConverter`.
+type ConverterFunc func(s string) (interface{}, error)
+
+// RegisterConverter modifies a Decoder to convert tokens into values of type
+// t with a custom function, replacing any converter previously registered for
+// t.  Custom converters are used before any built-in conversion, so they may
+// add support for types that can't implement `encoding.TextUnmarshaler`, such
+// as types from other packages, or override the conversion of supported types.
+// Pointers to t and slices of t are handled like pointers and slices of any
+// other supported type.
+//
+// The function must return a value assignable to t, or nil for the zero
+// value.
+func (d *Decoder) RegisterConverter(t reflect.Type, fn ConverterFunc) *Decoder {
+	if d.reg == nil {
+		d.reg = &registry{convs: make(map[reflect.Type]ConverterFunc)}
+	}
+	d.reg.convs[t] = fn
+	d.reg.plans = nil
+	d.named = nil
+	return d
+}
+
+// A registry holds the custom converters registered on a Decoder.  Decoding
+// plans depend on custom converters, so a Decoder with a registry caches
+// plans in the registry instead of sharing them with other Decoders.  A nil
+// registry has no custom converters.
+type registry struct {
+	convs map[reflect.Type]ConverterFunc
+	plans map[reflect.Type]*decodePlan
+}
+
+// planFor returns the decoding plan for a type, using the registry's
+// converters.
+func (r *registry) planFor(t reflect.Type) *decodePlan {
+	if r == nil {
+		return planFor(t)
+	}
+	if p, ok := r.plans[t]; ok {
+		return p
+	}
+	if r.plans == nil {
+		r.plans = make(map[reflect.Type]*decodePlan)
+	}
+	p := newPlan(t, r)
+	r.plans[t] = p
+	return p
+}
+
+// converter returns a converter for a type with a custom converter, or nil
+// if there isn't one.
+func (r *registry) converter(t reflect.Type) converter {
+	if r == nil {
+		return nil
+	}
+	fn, ok := r.convs[t]
+	if !ok {
+		return nil
+	}
+	return func(d *Decoder, rv reflect.Value, s string) error {
+		v, err := fn(s)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		x := reflect.ValueOf(v)
+		if !x.Type().AssignableTo(t) {
+			return fmt.Errorf("custom converter for %s returned %s", t, x.Type())
+		}
+		rv.Set(x)
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+// point stands in for a type from another package that can't implement
+// encoding.TextUnmarshaler.
+type point struct {
+	X, Y int
+}
+
+func parsePoint(s string) (point, error) {
+	var p point
+	_, err := fmt.Sscanf(s, "(%d,%d)", &p.X, &p.Y)
+	if err != nil {
+		return point{}, errors.New("invalid point")
+	}
+	return p, nil
+}
+
+func TestRegisterConverter(t *testing.T) {
+	type shape struct {
+		Name   string
+		Origin *point
+		Points []point
+	}
+
+	register := func(d *strum.Decoder) *strum.Decoder {
+		return d.RegisterConverter(reflect.TypeOf(point{}), func(s string) (interface{}, error) {
+			return parsePoint(s)
+		})
+	}
+
+	t.Run("struct fields", func(t *testing.T) {
+		d := register(strum.NewDecoder(bytes.NewBufferString("tri (0,0) (1,0) (0,1)")))
+		var got shape
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := shape{"tri", &point{0, 0}, []point{{1, 0}, {0, 1}}}
+		isWantGot(t, want, got, "decoded shape")
+	})
+
+	t.Run("single value and slice", func(t *testing.T) {
+		d := register(strum.NewDecoder(bytes.NewBufferString("(1,2)\n(3,4) (5,6)")))
+		var p point
+		err := d.Decode(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, point{1, 2}, p, "decoded point")
+
+		var ps []point
+		err = d.Decode(&ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []point{{3, 4}, {5, 6}}, ps, "decoded points")
+	})
+
+	t.Run("conversion error", func(t *testing.T) {
+		d := register(strum.NewDecoder(bytes.NewBufferString("tri (0,0) nope")))
+		var got shape
+		err := d.Decode(&got)
+		errContains(t, err, `token 2 "nope": error decoding to shape.Points[0]: invalid point`, "decoding")
+	})
+
+	t.Run("wrong type returned", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("(1,2)")).RegisterConverter(
+			reflect.TypeOf(point{}),
+			func(s string) (interface{}, error) { return s, nil },
+		)
+		var got point
+		err := d.Decode(&got)
+		errContains(t, err, "custom converter for strum_test.point returned string", "decoding")
+	})
+
+	t.Run("overrides built-in conversion", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("YES no")).RegisterConverter(
+			reflect.TypeOf(true),
+			func(s string) (interface{}, error) { return strings.EqualFold(s, "yes"), nil },
+		)
+		var got []bool
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []bool{true, false}, got, "decoded bools")
+	})
+
+	t.Run("other decoders unaffected", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("tri (0,0)"))
+		var got shape
+		err := d.Decode(&got)
+		errContains(t, err, "cannot decode into struct strum_test.shape", "decoding")
+	})
+
+	t.Run("generic helper", func(t *testing.T) {
+		d := strum.RegisterConverterOf(strum.NewDecoder(bytes.NewBufferString("(1,2)\n(3,4)")), parsePoint)
+		got, err := strum.DecodeAllOf[point](d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []point{{1, 2}, {3, 4}}, got, "decoded points")
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errUnterminatedSingle = errors.New("unterminated single-quoted string")
+var errUnterminatedDouble = errors.New("unterminated double-quoted string")
+var errTrailingBackslash = errors.New("trailing backslash")
+
+// shellSplit splits a string into words following POSIX shell rules for
+// quoting, without any expansions.  Words are separated by unquoted
+// whitespace.  A backslash outside quotes preserves the following character.
+// Single quotes preserve every character up to the closing quote.  Double
+// quotes preserve every character up to the closing quote, except that a
+// backslash escapes `$`, "`", `"`, `\`, or a newline.  Quotes are removed from
+// the resulting words.  It also returns the offset where each word starts.
+func shellSplit(s string) ([]string, []int, error) {
+	words := make([]string, 0)
+	var offsets []int
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(s); {
+		if !inWord {
+			offsets = append(offsets[:len(words)], i)
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r == '\\':
+			if i >= len(s) {
+				return nil, nil, errTrailingBackslash
+			}
+			r, size = utf8.DecodeRuneInString(s[i:])
+			i += size
+			// An escaped newline is a line continuation.
+			if r != '\n' {
+				inWord = true
+				word.WriteRune(r)
+			}
+		case r == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i:], '\'')
+			if end < 0 {
+				return nil, nil, errUnterminatedSingle
+			}
+			word.WriteString(s[i : i+end])
+			i += end + 1
+		case r == '"':
+			inWord = true
+			closed := false
+			for i < len(s) && !closed {
+				r, size = utf8.DecodeRuneInString(s[i:])
+				i += size
+				switch r {
+				case '"':
+					closed = true
+				case '\\':
+					if i < len(s) && strings.IndexByte("$`\"\\\n", s[i]) >= 0 {
+						if s[i] != '\n' {
+							word.WriteByte(s[i])
+						}
+						i++
+					} else {
+						word.WriteRune(r)
+					}
+				default:
+					word.WriteRune(r)
+				}
+			}
+			if !closed {
+				return nil, nil, errUnterminatedDouble
+			}
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, offsets[:len(words)], nil
+}
+
+// WithQuotedFields modifies a Decoder to split fields on whitespace like
+// `strings.Fields`, but following POSIX shell quoting rules.  Single quotes,
+// double quotes, and backslash escapes may be used to include whitespace or
+// quote characters in a token, and the quotes and escapes are removed from
+// the tokens.  For example, `John "Q Public" it\'s` tokenizes to "John",
+// "Q Public", and "it's".  A quoted string that is open at the end of a line
+// continues onto the following lines of input, as does a line ending in a
+// backslash.  An Encoder configured with `WithQuoting` writes tokens that read
+// back as written.
+func (d *Decoder) WithQuotedFields() *Decoder {
+	d.withSpanTokenizer(shellSplit)
+	d.more = shellIncomplete
+	return d
+}
+
+// shellIncomplete reports whether a string ends inside a quoted string or
+// with a line continuation, so that it continues onto the next line.
+func shellIncomplete(s string) bool {
+	_, _, err := shellSplit(s)
+	return err == errUnterminatedSingle || err == errUnterminatedDouble || err == errTrailingBackslash
+}
+
+// shellQuote quotes a string, if needed, so that `shellSplit` reads it as a
+// single word with the same contents.  Strings that are empty or contain
+// whitespace, quotes, backslashes, or invalid UTF-8 are enclosed in single
+// quotes.  A single quote within the string closes the quotes, is escaped with
+// a backslash, and then reopens them.
+func shellQuote(s string) string {
+	if s != "" && utf8.ValidString(s) && strings.IndexFunc(s, needsShellQuote) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func needsShellQuote(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`'"\`, r)
+}
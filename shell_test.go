@@ -0,0 +1,115 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestQuotedFields(t *testing.T) {
+	cases := []struct {
+		label       string
+		input       string
+		want        [][]string
+		errContains string
+	}{
+		{
+			label: "unquoted",
+			input: "  a b\tc  ",
+			want:  [][]string{{"a", "b", "c"}},
+		},
+		{
+			label: "blank line",
+			input: "  ",
+			want:  [][]string{{}},
+		},
+		{
+			label: "double quotes",
+			input: `"John Smith" 42`,
+			want:  [][]string{{"John Smith", "42"}},
+		},
+		{
+			label: "single quotes",
+			input: `'John Smith' '"quoted"' 'back\slash'`,
+			want:  [][]string{{"John Smith", `"quoted"`, `back\slash`}},
+		},
+		{
+			label: "escapes in double quotes",
+			input: `"a \"b\" \\ \$ \x"`,
+			want:  [][]string{{`a "b" \ $ \x`}},
+		},
+		{
+			label: "backslash escapes",
+			input: `John\ Smith \'a\' \\`,
+			want:  [][]string{{"John Smith", "'a'", `\`}},
+		},
+		{
+			label: "adjacent quoted parts",
+			input: `a"b c"'d e'f`,
+			want:  [][]string{{"ab cd ef"}},
+		},
+		{
+			label: "empty quotes",
+			input: `'' "" x`,
+			want:  [][]string{{"", "", "x"}},
+		},
+		{
+			label: "quote continues across lines",
+			input: "'a\nb' c\nd",
+			want:  [][]string{{"a\nb", "c"}, {"d"}},
+		},
+		{
+			label: "backslash continues across lines",
+			input: "a\\\n b\nc",
+			want:  [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			label:       "unterminated single quote",
+			input:       "'a b",
+			errContains: "unterminated single-quoted string",
+		},
+		{
+			label:       "unterminated double quote",
+			input:       `"a b`,
+			errContains: "unterminated double-quoted string",
+		},
+		{
+			label:       "trailing backslash",
+			input:       `a b\`,
+			errContains: "trailing backslash",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithQuotedFields()
+			var got [][]string
+			for {
+				tokens, err := d.Tokens()
+				if err == io.EOF {
+					break
+				}
+				if c.errContains != "" {
+					errContains(t, err, c.errContains, "tokenizing")
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tokens)
+			}
+			if c.errContains != "" {
+				t.Fatalf("expected error containing '%s', but got no error", c.errContains)
+			}
+			isWantGot(t, c.want, got, "tokens")
+		})
+	}
+}
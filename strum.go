@@ -8,7 +8,8 @@
 // (such as from stdin) and convert tokens into simple Go types.
 //
 // Tokenization defaults to whitespace-separated fields, but strum supports
-// using delimiters, regular expressions, or a custom tokenizer.
+// using delimiters, CSV records, shell-style quoting, fixed-width columns,
+// regular expressions, or a custom tokenizer.
 //
 // A line with a single token can be unmarshaled into a single variable of any
 // supported type.
@@ -20,8 +21,31 @@
 // Trying to unmarshal multiple tokens into a single variable or too many tokens
 // for the number of fields in a struct will result in an error.  Having too few
 // tokens for the fields in a struct is allowed; remaining fields will be
-// zeroed.  When unmarshaling to a slice, decoded values are appended; existing
-// values are untouched.
+// zeroed, or decoded from a default in a tag such as `strum:",default=22"`.
+// A default also replaces an empty token, such as between adjacent separators,
+// and may be enclosed in single quotes to include commas.  A default must be
+// valid for its field with a Decoder's default settings, as it is checked
+// along with the rest of the struct before any input is read.  Fields
+// tagged `required` (`strum:",required"`), or all fields of a Decoder
+// configured with `WithStrict`, must have tokens or defaults instead.  When
+// unmarshaling to a slice, decoded values are appended; existing values are
+// untouched.
+//
+// By default, tokens are mapped to exported struct fields in order and
+// unexported fields are ignored.  A `strum` struct tag can change this: a tag
+// with a number (`strum:"2"`) maps the field to that token index, with
+// subsequent untagged fields continuing from there, and a tag of `strum:"-"`
+// skips the field entirely.  Tokens that fall between mapped indexes are
+// ignored.  Any other tag (`strum:"age"`) names the field for matching named
+// tokens, such as named regular expression subexpressions, instead of the
+// field name.
+//
+// A struct field may be a slice of a supported type if it is the last field.
+// Like a variadic function parameter, it collects all remaining tokens.
+// Similarly, a last string field with a `rest` option (`strum:",rest"`) gets
+// the remainder of the line verbatim, starting from its token, so free text at
+// the end of a line keeps its internal whitespace.  This requires a built-in
+// tokenizer, as a custom tokenizer doesn't report where tokens start.
 //
 // strum supports the following types:
 //
@@ -34,18 +58,39 @@
 //
 //  - time.Duration
 //  - time.Time
-//  - any type implementing encoding.TextUnmarshaler
-//  - pointers to supported types (which will auto-instantiate)
+//  - any type implementing encoding.TextUnmarshaler, including with a pointer
+//    receiver
+//  - pointers to supported types (which will auto-instantiate, unless the
+//    token is a null token from `WithNullTokens`)
+//  - any type with a custom converter from `RegisterConverter`
 //
 // For numeric types, all Go literal formats are supported, including base
-// prefixes (`0xff`) and underscores (`1_000_000`) for integers.
+// prefixes (`0xff`) and underscores (`1_000_000`) for integers.  This means a
+// leading zero indicates octal, so `WithIntBase` or a `base` tag option may
+// be used to parse integers in a fixed base instead.
 //
 // For time.Time, strum detects and parses  a wide varity of formats using the
 // github.com/araddon/dateparse library. By default, it favors United States
 // interpretation of MM/DD/YYYY and has time zone semantics equivalent to
-// `time.Parse`.  strum allows specifying a custom parser instead.
+// `time.Parse`.  strum allows specifying a custom parser instead.  A struct
+// field may instead have its own layout and, optionally, time zone, given by
+// tag options like `strum:",layout=2006-01-02,tz=Europe/Paris"`, which are
+// used with `time.ParseInLocation`.  Without a time zone, the layout is
+// interpreted in UTC.
+//
+// strum provides `DecodeAll` to unmarshal all lines of input at once, as well
+// as `Next` and `Scan` or `DecodeEach` to stream lines without collecting them.
+// Blank lines and comments may be skipped with `WithSkipBlankLines` and
+// `WithCommentPrefixes`, and long lines may be wrapped using
+// `WithLineContinuation`.
 //
-// strum provides `DecodeAll` to unmarshal all lines of input at once.
+// An `Encoder` does the reverse, writing values as lines of tokens that a
+// Decoder can read back, or writing structs as a table with aligned columns
+// and a header.
+//
+// Errors decoding a line are reported as a `*DecodeError`, which includes the
+// line number and, when a particular token fails to decode, the token and the
+// field it was decoded to.
 package strum
 
 import (
@@ -58,6 +103,7 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/araddon/dateparse"
 )
@@ -65,25 +111,69 @@ import (
 // A Tokenizer is a function that breaks an input string into tokens.
 type Tokenizer func(s string) ([]string, error)
 
+// A spanTokenizer is a tokenizer that also reports the byte offset in the
+// input string where each token starts, or -1 for a token that doesn't
+// appear in the input.
+type spanTokenizer func(s string) ([]string, []int, error)
+
 // A DateParser parses a string into a time.Time struct.
 type DateParser func(s string) (time.Time, error)
 
 // A Decoder converts an input stream into Go types.
 type Decoder struct {
-	s  *bufio.Scanner
-	t  Tokenizer
-	dp DateParser
+	s     *bufio.Scanner
+	t     Tokenizer
+	st    spanTokenizer // if set, t is st without offsets
+	dp    DateParser
+	names []string
+	more  func(s string) bool
+	skip  func(s string) bool
+
+	fixedTags   bool
+	emptyAbsent bool
+	strict      bool
+	nulls       []string
+
+	intBase       int
+	intBaseErr    error // returned when decoding, as WithIntBase can't fail
+	noUnderscores bool
+
+	reg *registry // custom converters, if any
+
+	skipBlank      bool
+	comments       []string
+	inlineComments bool
+	continuation   string
+
+	named map[reflect.Type]namedPlan
+
+	lineNum int    // number of lines read
+	recLine int    // line number where the current record starts
+	rec     string // text of the current record
+
+	// Iterator state
+	row      string
+	rowLine  int
+	hasRow   bool
+	buffered bool // readline returns rec without reading input
+	iterErr  error
+
+	header  bool
+	columns []string
 }
 
 // NewDecoder returns a Decoder that reads from r. The default Decoder will
 // tokenize with `strings.Fields` function. The default date parser uses
 // github.com/araddon/dateparse.ParseAny.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{
+	d := &Decoder{
 		s:  bufio.NewScanner(r),
-		t:  func(s string) ([]string, error) { return strings.Fields(s), nil },
 		dp: func(s string) (time.Time, error) { return dateparse.ParseAny(s) },
 	}
+	return d.withTokenizers(
+		func(s string) ([]string, error) { return strings.Fields(s), nil },
+		splitFields,
+	)
 }
 
 // WithDateParser modifies a Decoder to use a custom date parsing function.
@@ -95,36 +185,210 @@ func (d *Decoder) WithDateParser(dp DateParser) *Decoder {
 // WithTokenizer modifies a Decoder to use a custom tokenizing function.
 func (d *Decoder) WithTokenizer(t Tokenizer) *Decoder {
 	d.t = t
+	d.st = nil
+	d.names = nil
+	d.named = nil
+	d.more = nil
+	d.skip = nil
+	d.fixedTags = false
+	d.emptyAbsent = false
+	return d
+}
+
+// withSpanTokenizer modifies a Decoder to use a tokenizing function that
+// reports token offsets, as needed to decode `rest` fields.
+func (d *Decoder) withSpanTokenizer(st spanTokenizer) *Decoder {
+	return d.withTokenizers(
+		func(s string) ([]string, error) {
+			tokens, _, err := st(s)
+			return tokens, err
+		},
+		st,
+	)
+}
+
+// withTokenizers modifies a Decoder to use a tokenizing function, along with
+// an equivalent but slower one that also reports token offsets, which is only
+// used to decode `rest` fields.
+func (d *Decoder) withTokenizers(t Tokenizer, st spanTokenizer) *Decoder {
+	d.WithTokenizer(t)
+	d.st = st
 	return d
 }
 
+// splitFields splits a string around runs of whitespace like `strings.Fields`.
+func splitFields(s string) ([]string, []int, error) {
+	tokens := make([]string, 0)
+	var offsets []int
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			tokens = append(tokens, s[start:i])
+			offsets = append(offsets, start)
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, s[start:])
+		offsets = append(offsets, start)
+	}
+	return tokens, offsets, nil
+}
+
 // WithTokenRegexp modifies a Decoder to use a regular expression to extract
 // tokens.  The regular expression is called with `FindStringSubmatches` for
 // each line of input, so it must encompass an entire line of input.  If the
 // line fails to match or if the regular expression has no subexpressions, an
 // error is returned.
+//
+// If any subexpressions are named, like `(?P<Age>\d+)`, then decoding into a
+// struct matches subexpressions to fields by name instead of by position.
+// Names match a field's `strum` tag name if it has one or else the field
+// name, ignoring case.  Unnamed subexpressions are ignored and named
+// subexpressions that match an empty string leave their fields zeroed, so
+// optional groups may be omitted.  A named subexpression that doesn't match
+// any field is an error.
 func (d *Decoder) WithTokenRegexp(re *regexp.Regexp) *Decoder {
-	return d.WithTokenizer(
-		func(s string) ([]string, error) {
-			xs := re.FindStringSubmatch(s)
+	d.withSpanTokenizer(
+		func(s string) ([]string, []int, error) {
+			xs := re.FindStringSubmatchIndex(s)
 			if xs == nil {
-				return []string{}, errors.New("regexp failed to match line " + s)
+				return []string{}, nil, errors.New("regexp failed to match line " + s)
 			}
 			// A regexp without capture expressions is an error.
-			if len(xs) == 1 {
-				return []string{}, errors.New("regexp has no subexpressions")
+			if len(xs) == 2 {
+				return []string{}, nil, errors.New("regexp has no subexpressions")
 			}
 			// Drop the full match and return only submatches.
-			return xs[1:], nil
+			n := len(xs)/2 - 1
+			tokens := make([]string, n)
+			offsets := make([]int, n)
+			for i := range tokens {
+				start, end := xs[2*i+2], xs[2*i+3]
+				offsets[i] = start
+				if start >= 0 {
+					tokens[i] = s[start:end]
+				}
+			}
+			return tokens, offsets, nil
 		},
 	)
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			d.names = re.SubexpNames()[1:]
+			d.named = nil
+			break
+		}
+	}
+	return d
+}
+
+// WithHeader modifies a Decoder to treat the first line of input as a header.
+// The header line is tokenized like any other line and the tokens are used as
+// column names.  When decoding into a struct, each column is matched to a
+// field by name the same way as named regular expression subexpressions.  It
+// is an error if a column doesn't match any field.  A field without a matching
+// column is left zeroed or set to its default, unless it is tagged `required`
+// or the Decoder is configured with `WithStrict`, which makes it an error.
+// Empty values leave their fields zeroed or set to their defaults.
+//
+// The header is read automatically before the first line of data.  It is
+// only used for decoding structs; other types are decoded as usual.
+func (d *Decoder) WithHeader() *Decoder {
+	d.header = true
+	return d
+}
+
+// Header returns the column names of a Decoder configured with `WithHeader`,
+// consuming the header line of input if it hasn't been read yet.  It returns
+// `io.EOF` if there is no input.
+func (d *Decoder) Header() ([]string, error) {
+	if !d.header {
+		return nil, errors.New("decoder was not configured with a header")
+	}
+	if d.columns != nil {
+		return d.columns, nil
+	}
+
+	s, err := d.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := d.t(s)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if columns == nil {
+		columns = []string{}
+	}
+	d.columns = columns
+	d.named = nil
+
+	return d.columns, nil
+}
+
+// WithStrict modifies a Decoder to require a token for every field when
+// decoding into a struct, as if every field were tagged `required`.  A line
+// with too few tokens is an error naming the fields that are missing, instead
+// of leaving them zeroed.  A slice field that collects remaining tokens may
+// still be empty unless it is tagged `required`.
+func (d *Decoder) WithStrict() *Decoder {
+	d.strict = true
+	d.named = nil
+	return d
+}
+
+// WithNullTokens modifies a Decoder to treat any of the given tokens as a
+// null value, replacing any previous null tokens.  Instead of being converted,
+// a null token leaves a pointer nil or other value zeroed.  This applies to
+// struct fields, slice elements, and single values.  A struct field may also
+// have its own null tokens, given by `null` tag options such as
+// `strum:",null=NA,null=-"`.
+func (d *Decoder) WithNullTokens(tokens ...string) *Decoder {
+	d.nulls = append([]string(nil), tokens...)
+	return d
+}
+
+// WithIntBase modifies a Decoder to parse integers in a fixed base from 2 to
+// 36, instead of following Go literal syntax.  With a fixed base, prefixes like
+// `0x` aren't recognized and a leading zero doesn't imply octal, so with base
+// 10, "010" decodes to 10 rather than 8.  A base of 0 restores the default.
+// A struct field may have its own base, given by a tag option such as
+// `strum:",base=16"`.  Any other base is an error when decoding.
+func (d *Decoder) WithIntBase(base int) *Decoder {
+	if base == 1 || base < 0 || base > 36 {
+		d.intBaseErr = fmt.Errorf("invalid integer base %d", base)
+		return d
+	}
+	d.intBase = base
+	d.intBaseErr = nil
+	return d
+}
+
+// WithIntUnderscores modifies a Decoder to allow or reject underscores
+// between the digits of integers, such as "1_000_000".  They are allowed by
+// default, in any base.
+func (d *Decoder) WithIntUnderscores(allow bool) *Decoder {
+	d.noUnderscores = !allow
+	return d
 }
 
 // WithSplitOn modifies a Decoder to split fields on a separator string.
 func (d *Decoder) WithSplitOn(sep string) *Decoder {
-	return d.WithTokenizer(
-		func(s string) ([]string, error) {
-			return strings.Split(s, sep), nil
+	return d.withTokenizers(
+		func(s string) ([]string, error) { return strings.Split(s, sep), nil },
+		func(s string) ([]string, []int, error) {
+			tokens := strings.Split(s, sep)
+			offsets := make([]int, len(tokens))
+			start := 0
+			for i, tok := range tokens {
+				offsets[i] = start
+				start += len(tok) + len(sep)
+			}
+			return tokens, offsets, nil
 		},
 	)
 }
@@ -132,7 +396,15 @@ func (d *Decoder) WithSplitOn(sep string) *Decoder {
 // Tokens consumes a line of input and returns all strings generated by the
 // tokenizer.  It is used internally by `Decode`, but available for testing or
 // for skipping over a line of input that should not be decoded.
+//
+// Errors from tokenizing are returned as a `*DecodeError`.
 func (d *Decoder) Tokens() ([]string, error) {
+	d.recLine, d.rec = 0, ""
+	tokens, err := d.tokens()
+	return tokens, d.lineError(err)
+}
+
+func (d *Decoder) tokens() ([]string, error) {
 	s, err := d.readline()
 	if err != nil {
 		return nil, err
@@ -140,7 +412,117 @@ func (d *Decoder) Tokens() ([]string, error) {
 	return d.t(s)
 }
 
+// tokenSpans reads a line and returns it with its tokens and the offset of
+// each token in the line.
+func (d *Decoder) tokenSpans() (string, []string, []int, error) {
+	if d.st == nil {
+		return "", nil, nil, errors.New("rest fields require a tokenizer that reports token positions")
+	}
+	s, err := d.readline()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	tokens, offsets, err := d.st(s)
+	return s, tokens, offsets, err
+}
+
+// tokenNames returns the header columns or other token names used to map
+// tokens to struct fields, if any.  The header is read if it hasn't been.
+func (d *Decoder) tokenNames() ([]string, error) {
+	if d.header && d.columns == nil {
+		_, err := d.Header()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.columns != nil {
+		return d.columns, nil
+	}
+	return d.names, nil
+}
+
 func (d *Decoder) readline() (string, error) {
+	if d.buffered {
+		d.buffered = false
+		return d.rec, nil
+	}
+	if d.header && d.columns == nil {
+		_, err := d.Header()
+		if err != nil {
+			return "", err
+		}
+	}
+	return d.readRecord()
+}
+
+// WithLineContinuation modifies a Decoder to join a line ending with a
+// continuation marker, such as a backslash, to the following line before
+// tokenizing.  The marker is removed and nothing else is added, so whitespace
+// before the marker is kept to separate tokens.  Errors report the line
+// number where the joined line starts.  An empty marker disables joining.
+func (d *Decoder) WithLineContinuation(marker string) *Decoder {
+	d.continuation = marker
+	return d
+}
+
+// readRecord reads the next logical line of input, skipping blank or comment
+// lines as configured and lines the tokenizer ignores, and joining lines
+// that end with a continuation marker or while the tokenizer reports a record
+// is incomplete.  If input ends during a record, the incomplete record is
+// returned for the tokenizer to report an error.
+func (d *Decoder) readRecord() (string, error) {
+	s, err := d.scanline()
+	for err == nil && (d.ignoreLine(s) || d.skip != nil && d.skip(s)) {
+		s, err = d.scanline()
+	}
+	if err != nil {
+		return "", err
+	}
+	d.recLine, d.rec = d.lineNum, s
+
+	s, err = d.continueLine(d.stripComment(s))
+	if err != nil {
+		return "", err
+	}
+	d.rec = s
+
+	for d.more != nil && d.more(s) {
+		next, err := d.scanline()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+		next, err = d.continueLine(d.stripComment(next))
+		if err != nil {
+			return "", err
+		}
+		s += "\n" + next
+		d.rec = s
+	}
+
+	return s, nil
+}
+
+// continueLine joins lines to a line while it ends with the continuation
+// marker.  A marker at the end of input is removed.
+func (d *Decoder) continueLine(s string) (string, error) {
+	for d.continuation != "" && strings.HasSuffix(s, d.continuation) {
+		s = strings.TrimSuffix(s, d.continuation)
+		next, err := d.scanline()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+		s += d.stripComment(next)
+	}
+	return s, nil
+}
+
+func (d *Decoder) scanline() (string, error) {
 	if !(d.s.Scan()) {
 		err := d.s.Err()
 		if err != nil {
@@ -148,85 +530,175 @@ func (d *Decoder) readline() (string, error) {
 		}
 		return "", io.EOF
 	}
+	d.lineNum++
 	return d.s.Text(), nil
 }
 
 // Decode reads the next line of input and stores it in the value pointed to by
-// `v`. It returns `io.EOF` when no more data is available.
+// `v`. It returns `io.EOF` when no more data is available.  Errors decoding a
+// line are returned as a `*DecodeError`.
 func (d *Decoder) Decode(v interface{}) error {
 	destValue, err := extractDestValue(v)
 	if err != nil {
 		return fmt.Errorf("Decode: %w", err)
 	}
-	return d.decode(destValue)
+	return d.decodeRecord(destValue)
+}
+
+// decodeRecord decodes the next record of input into a destination and
+// annotates any error with the line number and text of the record.
+func (d *Decoder) decodeRecord(destValue reflect.Value) error {
+	d.recLine, d.rec = 0, ""
+	return d.lineError(d.decode(destValue))
 }
 
-// decode puts a single line of input into a destination. It invokes a type-aware,
-// decoding routine that determines whether the line must have a single token,
-// or be consumed as a line, or whether multiple tokens are decoded to a slice
-// or struct.  It also recursively dereferences pointers to find an element to
-// decode in case they are pointers to structs, slices, or text unmarshalers.
+// decode puts a single line of input into a destination, following the
+// cached decoding plan for the destination type.  The entire destination type
+// is validated before reading, so an undecodable type doesn't consume input.
 func (d *Decoder) decode(destValue reflect.Value) error {
-	// Handle certain types specially, not as their underlying data kind.
-	switch destValue.Type() {
-	case durationType:
-		return d.decodeSingleToken(destValue)
-	case timeType, timePtrType:
-		return d.decodeSingleToken(destValue)
-	}
-
-	// Handle text unmarshaler types
-	if isTextUnmarshaler(destValue) {
-		return d.decodeSingleToken(destValue)
-	}
-
-	switch destValue.Kind() {
-	case reflect.Bool:
-		return d.decodeSingleToken(destValue)
-	case reflect.String:
-		return d.decodeLine(destValue)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return d.decodeSingleToken(destValue)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return d.decodeSingleToken(destValue)
-	case reflect.Float32, reflect.Float64:
-		return d.decodeSingleToken(destValue)
-	case reflect.Struct:
-		return d.decodeStruct(destValue)
-	case reflect.Slice:
-		return d.decodeSlice(destValue)
-	case reflect.Ptr:
+	if d.intBaseErr != nil {
+		return d.intBaseErr
+	}
+	p := d.reg.planFor(destValue.Type())
+	if p.err != nil {
+		return p.err
+	}
+	return d.decodePlan(p, destValue)
+}
+
+// decodePlan invokes a decoding routine for the kind of plan.  It recursively
+// dereferences pointers to find an element to decode.
+func (d *Decoder) decodePlan(p *decodePlan, destValue reflect.Value) error {
+	switch p.kind {
+	case planToken:
+		return d.decodeSingleToken(p, destValue)
+	case planLine:
+		return d.decodeLine(p, destValue)
+	case planStruct:
+		return d.decodeStruct(p, destValue)
+	case planSlice:
+		return d.decodeSlice(p, destValue)
+	case planPtr:
+		// Pointers to tokens or lines are instantiated only after reading,
+		// so that a null token can leave them nil.
+		switch p.leaf().kind {
+		case planToken:
+			return d.decodeSingleToken(p, destValue)
+		case planLine:
+			return d.decodeLine(p, destValue)
+		}
 		maybeInstantiatePtr(destValue)
-		return d.decode(destValue.Elem())
+		return d.decodePlan(p.elem, destValue.Elem())
 	default:
-		return fmt.Errorf("cannot decode into type %s", destValue.Type())
+		return p.err
 	}
 }
 
-func (d *Decoder) decodeStruct(destValue reflect.Value) error {
-	tokens, err := d.Tokens()
+func (d *Decoder) decodeStruct(p *decodePlan, destValue reflect.Value) error {
+	destType := destValue.Type()
+	fields := p.fields
+
+	// Token names are known before reading a line, so an error mapping them
+	// to fields isn't an error for any one line.
+	names, err := d.tokenNames()
 	if err != nil {
 		return err
 	}
+	var np namedPlan
+	if names != nil {
+		np = d.namedFields(destType, p, names)
+		if np.err != nil {
+			d.recLine, d.rec = 0, ""
+			return np.err
+		}
+	}
 
-	destType := destValue.Type()
+	var line string
+	var tokens []string
+	var offsets []int
+	switch {
+	case d.fixedTags:
+		fields = p.colFields
+		line, tokens, offsets, err = d.fixedWidthTokens(p.cols)
+	case p.rest:
+		line, tokens, offsets, err = d.tokenSpans()
+	default:
+		tokens, err = d.tokens()
+	}
+	if err != nil {
+		return err
+	}
 
-	// Zero the struct so any prior fields are reset.
-	destValue.Set(reflect.New(destType).Elem())
+	switch {
+	case names != nil:
+		fields = np.fields
+		if d.columns != nil && len(tokens) > len(d.columns) && !np.variadic {
+			return fmt.Errorf("found %d tokens, but header has %d columns", len(tokens), len(d.columns))
+		}
+	case d.fixedTags:
+		// Fields and tokens correspond to tag columns.
+	default:
+		// Tokens past the highest mapped index have nowhere to go.
+		if len(tokens) > p.numTokens && !p.variadic {
+			return fmt.Errorf("too many tokens for struct %s", destType)
+		}
+	}
 
-	// Map tokens into argValue
-	numFields := destValue.NumField()
-	for i := range tokens {
-		if i >= numFields {
-			return fmt.Errorf("too many tokens for struct %s", destValue.Type())
+	// Named tokens may be absent, such as for an optional regexp group, as
+	// may fixed-width columns.  Any empty token is absent for a field with a
+	// default.
+	absent := func(f fieldInfo) bool {
+		return f.token < 0 || f.token >= len(tokens) ||
+			(names != nil || d.emptyAbsent || f.hasDefault) && tokens[f.token] == ""
+	}
+
+	var missing []string
+	for _, f := range fields {
+		if absent(f) && d.needsToken(f) {
+			missing = append(missing, f.name)
 		}
-		fieldName := destType.Name() + "." + destType.Field(i).Name
-		// PkgPath is empty for exported fields.  See https://pkg.go.dev/reflect#StructField
-		// In Go 1.17, this is available as `IsExported`.
-		if destType.Field(i).PkgPath != "" {
-			return fmt.Errorf("cannot decode to unexported field %s", fieldName)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tokens for fields %s", strings.Join(missing, ", "))
+	}
+
+	// Zero the struct so any prior fields are reset.
+	destValue.Set(reflect.Zero(destType))
+
+	// Map tokens into fields
+	for _, f := range fields {
+		if absent(f) {
+			if f.hasDefault {
+				err = d.decodeDefault(f, destValue.Field(f.index))
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		// A named slice or rest field only collects the rest of the tokens
+		// if it has the last name.
+		last := names == nil || f.token >= len(names)-1
+		switch {
+		case f.variadic:
+			end := len(tokens)
+			if !last {
+				end = f.token + 1
+			}
+			err = d.decodeVariadic(f, destValue.Field(f.index), tokens[f.token:end])
+		default:
+			s := tokens[f.token]
+			if f.rest && last && offsets[f.token] >= 0 {
+				s = line[offsets[f.token]:]
+			}
+			if containsString(f.nulls, s) {
+				continue
+			}
+			err = d.decodeToValue(f.name, f.conv, destValue.Field(f.index), s)
+			if err != nil {
+				return withTokenIndex(err, f.token)
+			}
 		}
-		err = d.decodeToValue(fieldName, destValue.Field(i), tokens[i])
 		if err != nil {
 			return err
 		}
@@ -235,32 +707,64 @@ func (d *Decoder) decodeStruct(destValue reflect.Value) error {
 	return nil
 }
 
-func (d *Decoder) decodeSlice(sliceValue reflect.Value) error {
-	sliceType := sliceValue.Type()
+// needsToken reports whether a field must have a token, as it is required and
+// has no default.
+func (d *Decoder) needsToken(f fieldInfo) bool {
+	return !f.hasDefault && (f.required || d.strict && !f.variadic)
+}
+
+// decodeDefault decodes the default value of a field whose token is absent.
+// A variadic field gets its default as a single element.
+func (d *Decoder) decodeDefault(f fieldInfo, fieldValue reflect.Value) error {
+	if f.variadic {
+		return d.decodeVariadic(f, fieldValue, []string{f.def})
+	}
+	return d.decodeToValue(f.name, f.conv, fieldValue, f.def)
+}
 
-	if !isDecodableValue(reflect.New(sliceType.Elem()).Elem()) {
-		return fmt.Errorf("decoding to this slice type not supported: %s", sliceValue.Type())
+// decodeVariadic decodes tokens into a new slice for a variadic struct field.
+func (d *Decoder) decodeVariadic(f fieldInfo, fieldValue reflect.Value, tokens []string) error {
+	sliceValue := reflect.MakeSlice(fieldValue.Type(), len(tokens), len(tokens))
+	for i, s := range tokens {
+		if d.isNull(s) || containsString(f.nulls, s) {
+			continue
+		}
+		err := f.conv(d, sliceValue.Index(i), s)
+		if err != nil {
+			return withTokenIndex(decodingError(fmt.Sprintf("%s[%d]", f.name, i), s, err), f.token+i)
+		}
 	}
+	fieldValue.Set(sliceValue)
+	return nil
+}
 
-	tokens, err := d.Tokens()
+func (d *Decoder) decodeSlice(p *decodePlan, sliceValue reflect.Value) error {
+	tokens, err := d.tokens()
 	if err != nil {
 		return err
 	}
 
+	// Grow the slice once, then decode into the new elements.
+	start := sliceValue.Len()
+	newElems := reflect.MakeSlice(sliceValue.Type(), len(tokens), len(tokens))
+	sliceValue.Set(reflect.AppendSlice(sliceValue, newElems))
+
 	for i, s := range tokens {
-		v := reflect.New(sliceType.Elem()).Elem()
-		err := d.decodeToValue(fmt.Sprintf("element %d", i), v, s)
+		if d.isNull(s) {
+			continue
+		}
+		err := p.conv(d, sliceValue.Index(start+i), s)
 		if err != nil {
-			return err
+			sliceValue.SetLen(start + i)
+			return withTokenIndex(decodingError(fmt.Sprintf("element %d", i), s, err), i)
 		}
-		sliceValue.Set(reflect.Append(sliceValue, v))
 	}
 
 	return nil
 }
 
-func (d *Decoder) decodeSingleToken(destValue reflect.Value) error {
-	tokens, err := d.Tokens()
+func (d *Decoder) decodeSingleToken(p *decodePlan, destValue reflect.Value) error {
+	tokens, err := d.tokens()
 	if err != nil {
 		return err
 	}
@@ -269,16 +773,32 @@ func (d *Decoder) decodeSingleToken(destValue reflect.Value) error {
 		return fmt.Errorf("decoding %s: expected 1 token, but found %d", destValue.Type(), len(tokens))
 	}
 
-	return d.decodeToValue(destValue.Type().String(), destValue, tokens[0])
+	err = d.decodeScalar(p, destValue, tokens[0])
+	if err != nil {
+		return withTokenIndex(err, 0)
+	}
+	return nil
 }
 
-func (d *Decoder) decodeLine(destValue reflect.Value) error {
+func (d *Decoder) decodeLine(p *decodePlan, destValue reflect.Value) error {
 	line, err := d.readline()
 	if err != nil {
 		return err
 	}
 
-	return d.decodeToValue(destValue.Type().String(), destValue, line)
+	return d.decodeScalar(p, destValue, line)
+}
+
+// decodeScalar decodes a token or line with a planToken or planLine plan, or
+// a planPtr plan leading to one.  Pointers are instantiated unless the string
+// is a null token, which leaves the outermost pointer nil.
+func (d *Decoder) decodeScalar(p *decodePlan, destValue reflect.Value, s string) error {
+	for p.kind == planPtr && !d.isNull(s) {
+		maybeInstantiatePtr(destValue)
+		destValue = destValue.Elem()
+		p = p.elem
+	}
+	return d.decodeToValue(destValue.Type().String(), p.conv, destValue, s)
 }
 
 // DecodeAll reads the remaining lines of input into `v`, where `v` must be a
@@ -305,7 +825,7 @@ func (d *Decoder) decodeAll(sliceValue reflect.Value) error {
 	// Decode every line into the slice
 	for {
 		rv := reflect.New(sliceType.Elem()).Elem()
-		err := d.decode(rv)
+		err := d.decodeRecord(rv)
 		if err != nil {
 			if err == io.EOF {
 				return nil
@@ -316,6 +836,59 @@ func (d *Decoder) decodeAll(sliceValue reflect.Value) error {
 	}
 }
 
+// DecodeAllTolerant works like `DecodeAll`, except that lines that fail to
+// decode are skipped instead of stopping decoding.  Values from lines that
+// decode successfully are appended to the slice.  If any lines fail, it
+// returns their errors as `DecodeErrors`.
+//
+// If `maxErrors` is positive, decoding stops after more than `maxErrors` lines
+// fail, so the returned `DecodeErrors` will have a length of `maxErrors+1`.
+// Errors that aren't specific to a line, such as I/O errors or invalid struct
+// tags, stop decoding immediately and are returned as is.
+func (d *Decoder) DecodeAllTolerant(v interface{}, maxErrors int) error {
+	sliceValue, err := extractDestSlice(v)
+	if err != nil {
+		return fmt.Errorf("DecodeAllTolerant: %w", err)
+	}
+	return d.decodeAllTolerant(sliceValue, maxErrors)
+}
+
+func (d *Decoder) decodeAllTolerant(sliceValue reflect.Value, maxErrors int) error {
+	sliceType := sliceValue.Type()
+
+	// Make a zero-length slice if it starts uninitialized
+	if sliceValue.IsNil() {
+		sliceValue.Set(reflect.MakeSlice(sliceType, 0, 1))
+	}
+
+	var errs DecodeErrors
+	for {
+		rv := reflect.New(sliceType.Elem()).Elem()
+		err := d.decodeRecord(rv)
+		if err == nil {
+			sliceValue.Set(reflect.Append(sliceValue, rv))
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			return err
+		}
+		errs = append(errs, de)
+		if maxErrors > 0 && len(errs) > maxErrors {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
 // Unmarshal parses the input data as newline delimited strings and appends the
 // result to the value pointed to by `v`, where `v` must be a pointer to a slice
 // of a type that would valid for Decode.  If `v` points to an uninitialized
@@ -8,6 +8,7 @@ package strum_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -427,7 +428,7 @@ func TestBadTargets(t *testing.T) {
 
 	type partPrivate struct {
 		First  string
-		second string
+		second string `strum:"1"`
 	}
 
 	lines := []string{
@@ -513,3 +514,429 @@ func TestUnmarshal(t *testing.T) {
 	}
 	isWantGot(t, want, xs, "unmarshal string")
 }
+
+func TestRegexpNamed(t *testing.T) {
+	type person struct {
+		Name  string
+		Age   int
+		Email string `strum:"mail"`
+	}
+
+	re := regexp.MustCompile(`^(?P<age>\d+)\s+(?P<Name>\S+)(?:\s+<(?P<mail>[^>]+)>)?$`)
+
+	cases := []struct {
+		label       string
+		re          *regexp.Regexp
+		input       string
+		want        person
+		errContains string
+	}{
+		{
+			label: "all groups",
+			re:    re,
+			input: "42 John <john@example.com>",
+			want:  person{Name: "John", Age: 42, Email: "john@example.com"},
+		},
+		{
+			label: "optional group omitted",
+			re:    re,
+			input: "42 John",
+			want:  person{Name: "John", Age: 42},
+		},
+		{
+			label: "unnamed groups ignored",
+			re:    regexp.MustCompile(`^(\S+)\s+(?P<Age>\d+)$`),
+			input: "John 42",
+			want:  person{Age: 42},
+		},
+		{
+			label:       "unknown name",
+			re:          regexp.MustCompile(`^(?P<Height>\d+)$`),
+			input:       "180",
+			errContains: `no field in struct strum_test.person matches name "Height"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithTokenRegexp(c.re)
+			var got person
+			err := d.Decode(&got)
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "decoding")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, got, "decode result")
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	type person struct {
+		Name   string
+		Age    int
+		Active bool `strum:"enabled"`
+	}
+
+	cases := []struct {
+		label       string
+		input       string
+		strict      bool
+		want        []person
+		errContains string
+	}{
+		{
+			label: "columns in field order",
+			input: "name age enabled\nJohn 42 true\nJane 23 false",
+			want:  []person{{"John", 42, true}, {"Jane", 23, false}},
+		},
+		{
+			label: "columns out of order",
+			input: "enabled Name AGE\ntrue John 42\nfalse Jane 23",
+			want:  []person{{"John", 42, true}, {"Jane", 23, false}},
+		},
+		{
+			label: "short rows leave fields zeroed",
+			input: "name age enabled\nJohn 42",
+			want:  []person{{"John", 42, false}},
+		},
+		{
+			label: "header only",
+			input: "name age enabled\n",
+			want:  []person{},
+		},
+		{
+			label: "empty input",
+			input: "",
+			want:  []person{},
+		},
+		{
+			label:       "unknown column",
+			input:       "name age enabled height\nJohn 42 true 180",
+			errContains: `no field in struct strum_test.person matches name "height"`,
+		},
+		{
+			label: "missing column leaves field zeroed",
+			input: "name enabled\nJohn true",
+			want:  []person{{"John", 0, true}},
+		},
+		{
+			label:       "missing column when strict",
+			input:       "name enabled\nJohn true",
+			strict:      true,
+			errContains: "no header column for fields person.Age",
+		},
+		{
+			label:       "too many tokens",
+			input:       "name age enabled\nJohn 42 true extra",
+			errContains: "found 4 tokens, but header has 3 columns",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithHeader()
+			if c.strict {
+				d.WithStrict()
+			}
+			got := []person{}
+			err := d.DecodeAll(&got)
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "decoding")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, got, "decode result")
+		})
+	}
+
+	t.Run("missing required column", func(t *testing.T) {
+		type member struct {
+			Name string
+			Age  int `strum:",required"`
+			ID   int `strum:",required,default=1"`
+		}
+		d := strum.NewDecoder(bytes.NewBufferString("name\nJohn")).WithHeader()
+		var got member
+		err := d.Decode(&got)
+		errContains(t, err, "no header column for fields member.Age", "decoding")
+	})
+}
+
+func TestHeaderAccessor(t *testing.T) {
+	d := strum.NewDecoder(bytes.NewBufferString("a\tb c\n1\t2"))
+	_, err := d.Header()
+	errContains(t, err, "not configured with a header", "header without WithHeader")
+
+	d = strum.NewDecoder(bytes.NewBufferString("a\tb c\n1\t2")).WithSplitOn("\t").WithHeader()
+	header, err := d.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, []string{"a", "b c"}, header, "header")
+
+	// Non-struct destinations decode as usual after the header.
+	var xs []int
+	err = d.Decode(&xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, []int{1, 2}, xs, "row after header")
+}
+
+func TestStrict(t *testing.T) {
+	type person struct {
+		Name string
+		Age  int
+		Tags []string
+	}
+
+	type named struct {
+		Name string
+		Age  int
+	}
+
+	cases := []struct {
+		label       string
+		input       string
+		setup       func(d *strum.Decoder)
+		want        []person
+		errContains string
+	}{
+		{
+			label: "all fields present",
+			input: "John 42 a b\nJane 23",
+			want:  []person{{"John", 42, []string{"a", "b"}}, {"Jane", 23, nil}},
+		},
+		{
+			label:       "too few tokens",
+			input:       "John",
+			errContains: "line 1: missing tokens for fields person.Age",
+		},
+		{
+			label: "empty input",
+			input: "",
+			want:  []person{},
+		},
+		{
+			label:       "blank line",
+			input:       " ",
+			errContains: "missing tokens for fields person.Name, person.Age",
+		},
+		{
+			label:       "empty header value",
+			input:       "name,age,tags\nJohn,",
+			setup:       func(d *strum.Decoder) { d.WithSplitOn(",").WithHeader() },
+			errContains: "line 2: missing tokens for fields person.Age",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.label, func(t *testing.T) {
+			d := strum.NewDecoder(bytes.NewBufferString(c.input)).WithStrict()
+			if c.setup != nil {
+				c.setup(d)
+			}
+			got := []person{}
+			err := d.DecodeAll(&got)
+			if c.errContains != "" {
+				errContains(t, err, c.errContains, "decoding")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			isWantGot(t, c.want, got, "decode result")
+		})
+	}
+
+	t.Run("unmatched named group", func(t *testing.T) {
+		re := regexp.MustCompile(`^(?P<name>\w+)(?: (?P<age>\d+))?$`)
+		d := strum.NewDecoder(bytes.NewBufferString("John")).WithTokenRegexp(re).WithStrict()
+		var got named
+		err := d.Decode(&got)
+		errContains(t, err, "missing tokens for fields named.Age", "decoding")
+	})
+}
+
+func TestNullTokens(t *testing.T) {
+	type reading struct {
+		Sensor string
+		Temp   *float64
+		Count  int
+		Codes  []int
+	}
+
+	type tagged struct {
+		Name  string
+		Score *int `strum:",null=NA,null=-"`
+		Note  string
+	}
+
+	temp := 21.5
+	three := 3
+
+	t.Run("decoder null tokens", func(t *testing.T) {
+		input := "a 21.5 3 1 2\nb null NA\nc NA null 1 NA 3\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithNullTokens("null", "NA")
+		var got []reading
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []reading{
+			{"a", &temp, 3, []int{1, 2}},
+			{"b", nil, 0, nil},
+			{"c", nil, 0, []int{1, 0, 3}},
+		}
+		isWantGot(t, want, got, "decoded readings")
+	})
+
+	t.Run("field null tokens", func(t *testing.T) {
+		input := "a 3 x\nb NA y\nc - z\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input))
+		var got []tagged
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []tagged{{"a", &three, "x"}, {"b", nil, "y"}, {"c", nil, "z"}}
+		isWantGot(t, want, got, "decoded records")
+	})
+
+	t.Run("field null tokens are per field", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("NA 3"))
+		var got struct {
+			Count int
+			Score *int `strum:",null=NA"`
+		}
+		err := d.Decode(&got)
+		errContains(t, err, `token 0 "NA"`, "decoding")
+	})
+
+	t.Run("slice elements", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("1 - 3")).WithNullTokens("-")
+		var got []int
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []int{1, 0, 3}, got, "decoded slice")
+	})
+
+	t.Run("empty token", func(t *testing.T) {
+		type record struct {
+			A string
+			B *int
+			C string
+		}
+		d := strum.NewDecoder(bytes.NewBufferString("a,,b")).WithSplitOn(",").WithNullTokens("")
+		var got record
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, record{"a", nil, "b"}, got, "decoded record")
+	})
+
+	t.Run("top-level pointers", func(t *testing.T) {
+		p := &three
+		d := strum.NewDecoder(bytes.NewBufferString("-\n")).WithNullTokens("-")
+		err := d.Decode(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != nil {
+			t.Errorf("decoded pointer: expected nil, got %d", *p)
+		}
+
+		s := new(string)
+		d = strum.NewDecoder(bytes.NewBufferString("-\n")).WithNullTokens("-")
+		err = d.Decode(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != nil {
+			t.Errorf("decoded line pointer: expected nil, got %q", *s)
+		}
+
+		d = strum.NewDecoder(bytes.NewBufferString("1\n-\n3\n")).WithNullTokens("-")
+		var got []*int
+		err = d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		one := 1
+		isWantGot(t, []*int{&one, nil, &three}, got, "decoded pointers")
+	})
+}
+
+func TestLineContinuation(t *testing.T) {
+	type command struct {
+		Name string
+		Args []string
+	}
+
+	t.Run("joined lines", func(t *testing.T) {
+		input := "ls -l \\\n  -a \\\n  /tmp\necho hi\ncat \\\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithLineContinuation(`\`)
+		var got []command
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []command{
+			{"ls", []string{"-l", "-a", "/tmp"}},
+			{"echo", []string{"hi"}},
+			{"cat", nil},
+		}
+		isWantGot(t, want, got, "commands")
+	})
+
+	t.Run("marker without whitespace", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("12\\\n34\n")).WithLineContinuation(`\`)
+		var got int
+		err := d.Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, 1234, got, "joined int")
+	})
+
+	t.Run("error line number", func(t *testing.T) {
+		input := "1 2\n3 \\\n4 \\\nx\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).WithLineContinuation(" &")
+		var got [][]int
+		err := d.DecodeAll(&got)
+		// The marker is " &", so lines ending in a backslash aren't joined.
+		errContains(t, err, `line 2: token 1 "\\"`, "decoding")
+
+		d = strum.NewDecoder(bytes.NewBufferString(input)).WithLineContinuation(`\`)
+		err = d.DecodeAll(&got)
+		var de *strum.DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("expected DecodeError, got %v", err)
+		}
+		isWantGot(t, 2, de.Line, "error line")
+		isWantGot(t, "3 4 x", de.Text, "error text")
+	})
+
+	t.Run("with comments", func(t *testing.T) {
+		input := "# list\nls -l \\\n  /tmp # temp\n"
+		d := strum.NewDecoder(bytes.NewBufferString(input)).
+			WithCommentPrefixes("#").
+			WithInlineComments().
+			WithLineContinuation(`\`)
+		var got []command
+		err := d.DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []command{{"ls", []string{"-l", "/tmp"}}}, got, "commands")
+	})
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WithRightAlignedNumbers modifies an Encoder to right-align columns of
+// integers and floats in tables written by `EncodeTable`.
+func (e *Encoder) WithRightAlignedNumbers() *Encoder {
+	e.alignNumbers = true
+	return e
+}
+
+// EncodeTable writes a slice of structs, or pointers to structs, as a table
+// with aligned columns.  The first line is a header of field names, using a
+// field's `strum` tag name if it has one, so the table can be read back by a
+// Decoder configured with `WithHeader`.  Columns are padded to a common width
+// and separated by the Encoder's separator, which must be whitespace.  A
+// slice field collects remaining tokens, so its elements are written as
+// separate cells after the last header column.
+//
+// Unless the Encoder is configured with `WithQuoting`, it is an error if a
+// cell is empty or contains whitespace, as it would not decode as a single
+// token.  The exception is a string field with the `rest` option, which
+// decodes from the rest of the line and so is never quoted.  It is always an
+// error if a cell contains a newline.
+func (e *Encoder) EncodeTable(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("EncodeTable: argument must be a slice or pointer to a slice, not %T", v)
+	}
+	structType := rv.Type().Elem()
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("EncodeTable: slice elements must be structs, not %s", rv.Type().Elem())
+	}
+	if !e.whitespaceSep() {
+		return fmt.Errorf("EncodeTable: separator %q must be whitespace", e.sep)
+	}
+
+	p := planFor(structType)
+	if p.err != nil {
+		return p.err
+	}
+
+	// Columns follow token order, without any gaps between tokens.
+	fields := append([]fieldInfo(nil), p.fields...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].token < fields[j].token })
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		cell, err := e.tableCell(f.key, false)
+		if err != nil {
+			return fmt.Errorf("header: %w", err)
+		}
+		header[i] = cell
+	}
+
+	rows := [][]string{header}
+	for i := 0; i < rv.Len(); i++ {
+		row, err := e.tableRow(fields, rv.Index(i))
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		rows = append(rows, row)
+	}
+
+	return e.writeTable(rows, e.rightAligned(structType, fields))
+}
+
+// tableRow formats the fields of a struct as table cells.
+func (e *Encoder) tableRow(fields []fieldInfo, rv reflect.Value) ([]string, error) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot encode nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+
+	row := make([]string, 0, len(fields))
+	for _, f := range fields {
+		tf := fieldFormat(f)
+		fieldValue := rv.Field(f.index)
+
+		var toks []string
+		var err error
+		if f.variadic {
+			toks, err = e.formatSlice(fieldValue, tf)
+		} else {
+			var tok string
+			tok, err = e.formatToken(fieldValue, tf)
+			toks = []string{tok}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+
+		for _, tok := range toks {
+			cell, err := e.tableCell(tok, f.rest)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			row = append(row, cell)
+		}
+	}
+	return row, nil
+}
+
+// tableCell quotes or checks a token for a table cell.
+func (e *Encoder) tableCell(tok string, rest bool) (string, error) {
+	switch {
+	case strings.ContainsAny(tok, "\r\n"):
+		return "", fmt.Errorf("cannot encode %q: contains a newline", tok)
+	case e.quote && rest:
+		return e.formatRest(tok)
+	case e.quote:
+		return shellQuote(tok), nil
+	case tok == "":
+		return "", fmt.Errorf("cannot encode an empty cell without quoting")
+	case !rest && strings.IndexFunc(tok, unicode.IsSpace) >= 0:
+		return "", fmt.Errorf("cannot encode %q: contains whitespace", tok)
+	}
+	return tok, nil
+}
+
+// rightAligned returns whether each column should be right-aligned.
+func (e *Encoder) rightAligned(structType reflect.Type, fields []fieldInfo) []bool {
+	right := make([]bool, len(fields))
+	if !e.alignNumbers {
+		return right
+	}
+	for i, f := range fields {
+		t := structType.Field(f.index).Type
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		right[i] = isNumberType(t)
+	}
+	return right
+}
+
+// isNumberType reports whether a type is formatted as an integer or float.
+func isNumberType(t reflect.Type) bool {
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return false
+	}
+	return isIntegerType(t) || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// writeTable writes rows of cells with padded columns.
+func (e *Encoder) writeTable(rows [][]string, right []bool) error {
+	var widths []int
+	for _, row := range rows {
+		for j, cell := range row {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		b.Reset()
+		for j, cell := range row {
+			if j > 0 {
+				b.WriteString(e.sep)
+			}
+			// Cells past the last column belong to the last column, which
+			// is a slice field.
+			alignRight := false
+			if len(right) > 0 {
+				alignRight = right[len(right)-1]
+				if j < len(right) {
+					alignRight = right[j]
+				}
+			}
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			switch {
+			case alignRight:
+				b.WriteString(pad + cell)
+			case j == len(row)-1:
+				// Don't pad the end of the line.
+				b.WriteString(cell)
+			default:
+				b.WriteString(cell + pad)
+			}
+		}
+		b.WriteString("\n")
+		_, err := io.WriteString(e.w, b.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
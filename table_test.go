@@ -0,0 +1,159 @@
+// Copyright 2021 by David A. Golden. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package strum_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xdg-go/strum"
+)
+
+func TestEncodeTable(t *testing.T) {
+	type process struct {
+		PID     int
+		Command string `strum:"cmd"`
+		CPU     float64
+		Uptime  time.Duration
+		Args    []string
+	}
+
+	procs := []process{
+		{1, "init", 0.5, time.Hour, nil},
+		{812, "sshd", 12.25, 90 * time.Second, []string{"-D", "-f", "/etc/ssh/sshd_config"}},
+	}
+
+	t.Run("left aligned", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := strum.NewEncoder(&buf).EncodeTable(procs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "" +
+			"PID cmd  CPU   Uptime Args\n" +
+			"1   init 0.5   1h0m0s\n" +
+			"812 sshd 12.25 1m30s  -D   -f /etc/ssh/sshd_config\n"
+		isWantGot(t, want, buf.String(), "table")
+
+		var got []process
+		err = strum.NewDecoder(&buf).WithHeader().DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, procs, got, "round trip")
+	})
+
+	t.Run("right-aligned numbers", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := strum.NewEncoder(&buf).WithSeparator("  ").WithRightAlignedNumbers().EncodeTable(&procs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "" +
+			"PID  cmd     CPU  Uptime  Args\n" +
+			"  1  init    0.5  1h0m0s\n" +
+			"812  sshd  12.25  1m30s   -D    -f  /etc/ssh/sshd_config\n"
+		isWantGot(t, want, buf.String(), "table")
+	})
+
+	t.Run("quoting", func(t *testing.T) {
+		type file struct {
+			Name string
+			Size *int
+		}
+		size := 1024
+		files := []*file{{"my notes.txt", &size}, {"", nil}}
+
+		var buf bytes.Buffer
+		err := strum.NewEncoder(&buf).WithQuoting().EncodeTable(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "" +
+			"Name           Size\n" +
+			"'my notes.txt' 1024\n" +
+			"''             ''\n"
+		isWantGot(t, want, buf.String(), "table")
+
+		var got []*file
+		err = strum.NewDecoder(&buf).WithQuotedFields().WithHeader().WithNullTokens("").DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, []*file{{"my notes.txt", &size}, {}}, got, "round trip")
+	})
+
+	t.Run("rest field", func(t *testing.T) {
+		type commit struct {
+			Hash    string
+			Subject string `strum:",rest"`
+		}
+		commits := []commit{{"abc123", "Fix the bug"}, {"d4", "Add a feature"}}
+
+		var buf bytes.Buffer
+		err := strum.NewEncoder(&buf).EncodeTable(commits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, "Hash   Subject\nabc123 Fix the bug\nd4     Add a feature\n", buf.String(), "table")
+
+		var got []commit
+		err = strum.NewDecoder(&buf).WithHeader().DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, commits, got, "round trip")
+
+		// With quoting, a rest field is still written as-is.
+		commits = []commit{{"a b", `Say "hi"`}}
+		buf.Reset()
+		err = strum.NewEncoder(&buf).WithQuoting().EncodeTable(commits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, "Hash  Subject\n'a b' Say \"hi\"\n", buf.String(), "quoted table")
+
+		got = nil
+		err = strum.NewDecoder(&buf).WithQuotedFields().WithHeader().DecodeAll(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, commits, got, "quoted round trip")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		type named struct {
+			Name string
+		}
+
+		cases := []struct {
+			label       string
+			value       interface{}
+			setup       func(e *strum.Encoder)
+			errContains string
+		}{
+			{"not a slice", named{}, nil, "EncodeTable: argument must be a slice"},
+			{"not structs", []int{1}, nil, "EncodeTable: slice elements must be structs, not int"},
+			{"empty cell", []named{{""}}, nil, "element 0: field named.Name: cannot encode an empty cell without quoting"},
+			{"whitespace", []named{{"a b"}}, nil, `cannot encode "a b": contains whitespace`},
+			{"newline", []named{{"a\nb"}}, func(e *strum.Encoder) { e.WithQuoting() }, "contains a newline"},
+			{"separator", []named{{"a"}}, func(e *strum.Encoder) { e.WithSeparator("|") }, `separator "|" must be whitespace`},
+			{"nil element", []*named{nil}, nil, "cannot encode nil *strum_test.named"},
+		}
+
+		for _, c := range cases {
+			var buf bytes.Buffer
+			e := strum.NewEncoder(&buf)
+			if c.setup != nil {
+				c.setup(e)
+			}
+			err := e.EncodeTable(c.value)
+			errContains(t, err, c.errContains, c.label)
+		}
+	})
+}
@@ -15,17 +15,17 @@ import (
 	"time"
 )
 
-func decodingError(name string, err error) error {
-	return fmt.Errorf("error decoding to %s: %w", name, err)
-}
-
 var durationType = reflect.TypeOf(time.Duration(0))
 var timeType = reflect.TypeOf(time.Time{})
 var timePtrType = reflect.TypeOf(&time.Time{})
 
-// isDecodableValue duplicates the logic tree of `decodeToValue` to allow input
+// isDecodableValue duplicates the logic tree of `newConverter` to allow input
 // validation before decoding is called. This supports better error messages.
-func isDecodableValue(rv reflect.Value) bool {
+func isDecodableValue(rv reflect.Value, r *registry) bool {
+	if r.converter(rv.Type()) != nil {
+		return true
+	}
+
 	switch rv.Type() {
 	case durationType:
 		return true
@@ -55,86 +55,270 @@ func isDecodableValue(rv reflect.Value) bool {
 
 var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 
+// isTextUnmarshaler reports whether a value implements encoding.TextUnmarshaler
+// directly or, if it is addressable, with a pointer receiver.
 func isTextUnmarshaler(rv reflect.Value) bool {
-	return rv.Type().Implements(textUnmarshalerType)
+	return rv.Type().Implements(textUnmarshalerType) ||
+		(rv.CanAddr() && reflect.PointerTo(rv.Type()).Implements(textUnmarshalerType))
 }
 
-func (d *Decoder) decodeToValue(name string, rv reflect.Value, s string) error {
+// A converter decodes a token into a value of a particular type.  Converters
+// are built once per type by `newConverter`, so they don't repeat type
+// analysis or method lookup for every token.
+type converter func(d *Decoder, rv reflect.Value, s string) error
+
+// newConverter returns a converter for a type.  It follows the logic tree of
+// `isDecodableValue`, plus pointers to decodable types, which are instantiated
+// when decoding.  Custom converters in the registry take precedence.  For any
+// other type, the converter returns an error.
+func newConverter(t reflect.Type, r *registry) converter {
+	if conv := r.converter(t); conv != nil {
+		return conv
+	}
+
 	// Custom parsing for certain types
-	switch rv.Type() {
+	switch t {
 	case durationType:
-		t, err := time.ParseDuration(s)
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.Set(reflect.ValueOf(t))
-		return nil
+		return convertDuration
 	case timeType:
-		t, err := d.dp(s)
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.Set(reflect.ValueOf(t))
-		return nil
+		return convertTime
 	case timePtrType:
-		// Handle recursively to avoid using TextUnmarshaler
-		maybeInstantiatePtr(rv)
-		return d.decodeToValue(name, rv.Elem(), s)
+		// Handle via the pointer to avoid using TextUnmarshaler
+		return ptrConverter(convertTime)
 	}
 
-	// Handle TextUnmarshaler types
-	if isTextUnmarshaler(rv) {
-		maybeInstantiatePtr(rv)
-		f := rv.MethodByName("UnmarshalText")
-		xs := []byte(s)
-		args := []reflect.Value{reflect.ValueOf(xs)}
-		ret := f.Call(args)
-		if !ret[0].IsNil() {
-			return decodingError(name, ret[0].Interface().(error))
-		}
-		return nil
+	// Handle TextUnmarshaler types.  Decoding destinations are always
+	// addressable, so pointer receivers may be used.
+	if t.Implements(textUnmarshalerType) {
+		return convertTextUnmarshaler
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return convertAddrTextUnmarshaler
 	}
 
-	switch rv.Kind() {
+	switch t.Kind() {
 	case reflect.Bool:
-		b, err := strconv.ParseBool(strings.ToLower(s))
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.SetBool(b)
+		return convertBool
 	case reflect.String:
-		rv.SetString(s)
+		return convertString
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i, err := strconv.ParseInt(s, 0, rv.Type().Bits())
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.SetInt(i)
+		return convertInt
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		i, err := strconv.ParseUint(s, 0, rv.Type().Bits())
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.SetUint(i)
+		return convertUint
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(s, rv.Type().Bits())
-		if err != nil {
-			return decodingError(name, err)
-		}
-		rv.SetFloat(f)
+		return convertFloat
 	case reflect.Ptr:
-		maybeInstantiatePtr(rv)
-		return d.decodeToValue(name, rv.Elem(), s)
+		return ptrConverter(newConverter(t.Elem(), r))
 	default:
-		return decodingError(name, fmt.Errorf("unsupported type %s", rv.Type()))
+		err := fmt.Errorf("unsupported type %s", t)
+		return func(d *Decoder, rv reflect.Value, s string) error {
+			return err
+		}
+	}
+}
+
+// decodeToValue converts a token into a value, wrapping any error with the
+// name of the destination.
+func (d *Decoder) decodeToValue(name string, conv converter, rv reflect.Value, s string) error {
+	if d.isNull(s) {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	err := conv(d, rv, s)
+	if err != nil {
+		return decodingError(name, s, err)
+	}
+	return nil
+}
+
+// isNull reports whether a token is one of the Decoder's null tokens.
+func (d *Decoder) isNull(s string) bool {
+	return containsString(d.nulls, s)
+}
+
+func containsString(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func ptrConverter(conv converter) converter {
+	return func(d *Decoder, rv reflect.Value, s string) error {
+		maybeInstantiatePtr(rv)
+		return conv(d, rv.Elem(), s)
+	}
+}
+
+func convertDuration(d *Decoder, rv reflect.Value, s string) error {
+	t, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	rv.SetInt(int64(t))
+	return nil
+}
+
+func convertTime(d *Decoder, rv reflect.Value, s string) error {
+	t, err := d.dp(s)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// layoutConverter returns a converter for time.Time that parses with a fixed
+// layout in a location, instead of using the Decoder's date parser.
+func layoutConverter(layout string, loc *time.Location) converter {
+	return func(d *Decoder, rv reflect.Value, s string) error {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+}
+
+func convertTextUnmarshaler(d *Decoder, rv reflect.Value, s string) error {
+	maybeInstantiatePtr(rv)
+	return rv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+func convertAddrTextUnmarshaler(d *Decoder, rv reflect.Value, s string) error {
+	return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+func convertBool(d *Decoder, rv reflect.Value, s string) error {
+	b, err := strconv.ParseBool(strings.ToLower(s))
+	if err != nil {
+		return err
+	}
+	rv.SetBool(b)
+	return nil
+}
+
+func convertString(d *Decoder, rv reflect.Value, s string) error {
+	rv.SetString(s)
+	return nil
+}
+
+func convertInt(d *Decoder, rv reflect.Value, s string) error {
+	return d.setInt(rv, s, d.intBase)
+}
+
+func convertUint(d *Decoder, rv reflect.Value, s string) error {
+	return d.setUint(rv, s, d.intBase)
+}
+
+// intBaseConverter returns a converter for signed or unsigned integers that
+// parses in a given base instead of the Decoder's base.
+func intBaseConverter(base int) converter {
+	return func(d *Decoder, rv reflect.Value, s string) error {
+		switch rv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return d.setUint(rv, s, base)
+		default:
+			return d.setInt(rv, s, base)
+		}
+	}
+}
+
+func (d *Decoder) setInt(rv reflect.Value, s string, base int) error {
+	s, err := d.intDigits(s, base)
+	if err != nil {
+		return err
 	}
+	i, err := strconv.ParseInt(s, base, rv.Type().Bits())
+	if err != nil {
+		return err
+	}
+	rv.SetInt(i)
+	return nil
+}
 
+func (d *Decoder) setUint(rv reflect.Value, s string, base int) error {
+	s, err := d.intDigits(s, base)
+	if err != nil {
+		return err
+	}
+	i, err := strconv.ParseUint(s, base, rv.Type().Bits())
+	if err != nil {
+		return err
+	}
+	rv.SetUint(i)
 	return nil
 }
 
+// intDigits applies the Decoder's underscore setting to an integer token.
+// `strconv` allows underscores only for base 0, so for other bases they are
+// checked and removed here.
+func (d *Decoder) intDigits(s string, base int) (string, error) {
+	if !strings.Contains(s, "_") {
+		return s, nil
+	}
+	if d.noUnderscores {
+		return "", fmt.Errorf("underscores not allowed in integer %q", s)
+	}
+	if base == 0 {
+		return s, nil
+	}
+
+	// Underscores must separate digits, after any sign.
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) < len(s)-1 || strings.HasPrefix(digits, "_") ||
+		strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		return "", fmt.Errorf("invalid underscores in integer %q", s)
+	}
+	return strings.ReplaceAll(s, "_", ""), nil
+}
+
+func convertFloat(d *Decoder, rv reflect.Value, s string) error {
+	f, err := strconv.ParseFloat(s, rv.Type().Bits())
+	if err != nil {
+		return err
+	}
+	rv.SetFloat(f)
+	return nil
+}
+
+// isIntegerType reports whether a type is converted as a signed or unsigned
+// integer, rather than with special handling like time.Duration.
+func isIntegerType(t reflect.Type) bool {
+	if t == durationType || t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
 func maybeInstantiatePtr(rv reflect.Value) {
 	if rv.Kind() == reflect.Ptr && rv.IsNil() {
 		np := reflect.New(rv.Type().Elem())
 		rv.Set(np)
 	}
 }
+
+// isDecodableField reports whether a struct field of a given type can hold a
+// single token.  Unlike slice elements, fields may be pointers to decodable
+// types, which `decodeToValue` instantiates.
+func isDecodableField(t reflect.Type, r *registry) bool {
+	for t.Kind() == reflect.Ptr && !isDecodableValue(reflect.New(t).Elem(), r) {
+		t = t.Elem()
+	}
+	return isDecodableValue(reflect.New(t).Elem(), r)
+}
+
+// isVariadicField reports whether a struct field of a given type is a slice
+// that can hold several tokens, like a slice passed to `Decode`.
+func isVariadicField(t reflect.Type, r *registry) bool {
+	return t.Kind() == reflect.Slice && isDecodableValue(reflect.New(t.Elem()).Elem(), r)
+}
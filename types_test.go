@@ -12,6 +12,7 @@ import (
 	"math"
 	"math/big"
 	"math/bits"
+	"net"
 	"testing"
 	"time"
 
@@ -217,6 +218,123 @@ func TestDecodeInts(t *testing.T) {
 	testTestCases(t, cases)
 }
 
+func TestIntBase(t *testing.T) {
+	type record struct {
+		ID   int
+		Zip  uint
+		Mask *uint8 `strum:",base=2"`
+		Hex  int32  `strum:",base=16"`
+	}
+
+	mask := uint8(5)
+
+	decodeWith := func(setup func(d *strum.Decoder)) func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+		return func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+			setup(d)
+			var got record
+			err := d.Decode(&got)
+			return got, err
+		}
+	}
+	base10 := decodeWith(func(d *strum.Decoder) { d.WithIntBase(10) })
+
+	cases := []testcase{
+		{
+			label:  "default base with tags",
+			input:  "010 0x10 101 ff",
+			want:   func() interface{} { return record{8, 16, &mask, 255} },
+			decode: decodeWith(func(d *strum.Decoder) {}),
+		},
+		{
+			label:  "leading zeros in base 10",
+			input:  "010 02134 101 -7f",
+			want:   func() interface{} { return record{10, 2134, &mask, -127} },
+			decode: base10,
+		},
+		{
+			label:  "underscores in fixed base",
+			input:  "-1_000 1_0 1_01 f_f",
+			want:   func() interface{} { return record{-1000, 10, &mask, 255} },
+			decode: base10,
+		},
+		{
+			label:       "prefix in base 10",
+			input:       "0x10",
+			decode:      base10,
+			errContains: `token 0 "0x10": error decoding to record.ID`,
+		},
+		{
+			label:       "misplaced underscore",
+			input:       "1__0",
+			decode:      base10,
+			errContains: `invalid underscores in integer "1__0"`,
+		},
+		{
+			label:       "underscores disallowed",
+			input:       "1_000",
+			decode:      decodeWith(func(d *strum.Decoder) { d.WithIntUnderscores(false) }),
+			errContains: `underscores not allowed in integer "1_000"`,
+		},
+		{
+			label:       "bad digit for tag base",
+			input:       "1 2 3",
+			decode:      base10,
+			errContains: `token 2 "3": error decoding to record.Mask`,
+		},
+		{
+			label: "invalid tag base",
+			input: "1",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					ID int `strum:",base=1"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `invalid integer base "1"`,
+		},
+		{
+			label: "tag base on non-integer",
+			input: "1",
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got struct {
+					D time.Duration `strum:",base=10"`
+				}
+				err := d.Decode(&got)
+				return got, err
+			},
+			errContains: `tag option "base" requires an integer field, not time.Duration`,
+		},
+		{
+			label:       "invalid decoder base",
+			input:       "1",
+			decode:      decodeWith(func(d *strum.Decoder) { d.WithIntBase(1) }),
+			errContains: "invalid integer base 1",
+		},
+		{
+			label:       "decoder base too large",
+			input:       "1",
+			decode:      decodeWith(func(d *strum.Decoder) { d.WithIntBase(99) }),
+			errContains: "invalid integer base 99",
+		},
+	}
+
+	testTestCases(t, cases)
+
+	t.Run("invalid base doesn't consume input", func(t *testing.T) {
+		d := strum.NewDecoder(bytes.NewBufferString("010\n")).WithIntBase(99)
+		var got int
+		err := d.Decode(&got)
+		errContains(t, err, "invalid integer base 99", "decoding")
+
+		err = d.WithIntBase(10).Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isWantGot(t, 10, got, "decoded after fixing base")
+	})
+}
+
 func TestDecodeUints(t *testing.T) {
 	type uints struct {
 		U   uint
@@ -472,6 +590,27 @@ func TestDecodeTextUnmarshaler(t *testing.T) {
 			decode:      bigratDecoder,
 			errContains: "cannot unmarshal",
 		},
+		{
+			label: "pointer receiver",
+			input: "10.0.0.1",
+			want:  func() interface{} { return net.ParseIP("10.0.0.1") },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got net.IP
+				err := d.Decode(&got)
+				return got, err
+			},
+		},
+		{
+			label: "pointer receiver struct",
+			input: "1/3",
+			want:  func() interface{} { return *big.NewRat(1, 3) },
+			decode: func(t *testing.T, d *strum.Decoder) (interface{}, error) {
+				var got big.Rat
+				err := d.Decode(&got)
+				return got, err
+			},
+			normalize: func(v interface{}) interface{} { r := v.(big.Rat); return r.String() },
+		},
 	}
 
 	testTestCases(t, cases)
@@ -490,6 +629,49 @@ func TestUnsupportedType(t *testing.T) {
 	errContains(t, err, "unsupported type complex128", "unsupported")
 }
 
+func TestValidateBeforeReading(t *testing.T) {
+	type unsupported struct {
+		Name   string
+		C      complex128
+		M      *map[string]int
+		hidden int `strum:"2"`
+		Nested struct{ A int }
+	}
+
+	r := bytes.NewBufferString("42\n")
+	d := strum.NewDecoder(r)
+
+	wantErrs := []string{
+		"cannot decode into struct strum_test.unsupported: ",
+		"field unsupported.C: unsupported type complex128; ",
+		"field unsupported.M: unsupported type *map[string]int; ",
+		"cannot decode to unexported field unsupported.hidden; ",
+		"field unsupported.Nested: unsupported type struct { A int }",
+	}
+
+	var u unsupported
+	err := d.Decode(&u)
+	for _, want := range wantErrs {
+		errContains(t, err, want, "struct")
+	}
+
+	var pu *[]unsupported
+	err = d.Decode(&pu)
+	errContains(t, err, "decoding to this slice type not supported", "pointer to slice of struct")
+
+	var pc **complex64
+	err = d.Decode(&pc)
+	errContains(t, err, "cannot decode into type complex64", "pointer to pointer to complex")
+
+	// None of the failures should have consumed the line.
+	var x int
+	err = d.Decode(&x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isWantGot(t, 42, x, "line after failures")
+}
+
 func TestPointers(t *testing.T) {
 	type sxWithBoolHandle struct {
 		B **bool